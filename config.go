@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/prometheus/common/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// config holds the settings of the configuration file, all of which are
+// safe to change while running and are reapplied on SIGHUP.
+type config struct {
+	LogLevel string `yaml:"log_level"`
+}
+
+func loadConfig(path string) (*config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config %q: %s", path, err)
+	}
+
+	var c config
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return nil, fmt.Errorf("error parsing config %q: %s", path, err)
+	}
+	return &c, nil
+}
+
+// loadHelpOverrides loads the file at path mapping metric names to the help
+// text to export them with.
+func loadHelpOverrides(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading help overrides %q: %s", path, err)
+	}
+
+	var help map[string]string
+	if err := yaml.UnmarshalStrict(content, &help); err != nil {
+		return nil, fmt.Errorf("error parsing help overrides %q: %s", path, err)
+	}
+	return help, nil
+}
+
+// applyConfig loads the configuration file at path and applies it.
+func applyConfig(path string) error {
+	c, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if c.LogLevel != "" {
+		if err := log.Base().SetLevel(c.LogLevel); err != nil {
+			return fmt.Errorf("error applying config %q: %s", path, err)
+		}
+	}
+	return nil
+}
+
+// reloadOnSIGHUP reapplies the configuration file at path whenever the
+// exporter receives SIGHUP. A broken file leaves the current settings in
+// place.
+func reloadOnSIGHUP(path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := applyConfig(path); err != nil {
+				log.Errorf("failed to reload config: %s", err)
+				continue
+			}
+			log.Infof("reloaded config %s", path)
+		}
+	}()
+}
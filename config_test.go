@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/common/log"
+)
+
+func TestApplyConfig(t *testing.T) {
+	path := writeWebConfig(t, "log_level: debug\n")
+	defer os.Remove(path)
+	if err := applyConfig(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer log.Base().SetLevel("info")
+
+	for _, content := range []string{
+		"log_level: loud\n",
+		"scrape_interval: 1s\n",
+	} {
+		path := writeWebConfig(t, content)
+		defer os.Remove(path)
+		if err := applyConfig(path); err == nil {
+			t.Errorf("expected error for config %q", content)
+		}
+	}
+}
+
+func TestLoadHelpOverrides(t *testing.T) {
+	path := writeWebConfig(t, "passenger_up: Whether passenger is reachable.\n")
+	defer os.Remove(path)
+
+	help, err := loadHelpOverrides(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := "Whether passenger is reachable.", help["passenger_up"]; want != got {
+		t.Fatalf("incorrect help: wanted %q, got %q", want, got)
+	}
+
+	path = writeWebConfig(t, "passenger_up: [not, text]\n")
+	defer os.Remove(path)
+	if _, err := loadHelpOverrides(path); err == nil {
+		t.Fatal("expected error for non-text help")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// instanceDirPrefix prefixes the directory passenger creates for each
+// instance in the instance registry directory. The remainder is the instance
+// name accepted by passenger-status.
+const instanceDirPrefix = "passenger."
+
+// instanceDiscoverer collects metrics from every passenger instance found in
+// the instance registry directory, using one Exporter per instance.
+type instanceDiscoverer struct {
+	mutex sync.Mutex
+
+	registryDir string
+	newExporter func(instance string) *Exporter
+	exporters   map[string]*Exporter
+
+	// describes the metrics of all instances.
+	template *Exporter
+}
+
+func newInstanceDiscoverer(registryDir string, newExporter func(instance string) *Exporter) *instanceDiscoverer {
+	return &instanceDiscoverer{
+		registryDir: registryDir,
+		newExporter: newExporter,
+		exporters:   make(map[string]*Exporter),
+		template:    newExporter(""),
+	}
+}
+
+// defaultRegistryDir returns the instance registry directory passenger uses
+// unless configured otherwise.
+func defaultRegistryDir() string {
+	if dir := os.Getenv("PASSENGER_INSTANCE_REGISTRY_DIR"); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// discoverInstances returns the names of the passenger instances registered
+// in dir.
+func discoverInstances(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), instanceDirPrefix) {
+			instances = append(instances, strings.TrimPrefix(entry.Name(), instanceDirPrefix))
+		}
+	}
+	return instances, nil
+}
+
+// Describe describes the metrics exported for each instance.
+func (d *instanceDiscoverer) Describe(ch chan<- *prometheus.Desc) {
+	d.template.Describe(ch)
+}
+
+// Collect discovers the current passenger instances and collects their
+// metrics concurrently.
+func (d *instanceDiscoverer) Collect(ch chan<- prometheus.Metric) {
+	exporters, err := d.discover()
+	if err != nil {
+		log.Errorf("failed to discover passenger instances: %s", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, e := range exporters {
+		wg.Add(1)
+		go func(e *Exporter) {
+			defer wg.Done()
+			e.Collect(ch)
+		}(e)
+	}
+	wg.Wait()
+}
+
+// discover returns the exporters of the current passenger instances by name.
+// Exporters of instances which disappeared are dropped.
+func (d *instanceDiscoverer) discover() (map[string]*Exporter, error) {
+	instances, err := discoverInstances(d.registryDir)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	exporters := make(map[string]*Exporter, len(instances))
+	for _, instance := range instances {
+		e, ok := d.exporters[instance]
+		if !ok {
+			e = d.newExporter(instance)
+		}
+		exporters[instance] = e
+	}
+	d.exporters = exporters
+	return exporters, nil
+}
+
+// debugStatusHandler serves the status of the passenger instance named by
+// the instance parameter, like Exporter.debugStatusHandler.
+func (d *instanceDiscoverer) debugStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		instance := r.URL.Query().Get("instance")
+		if instance == "" {
+			http.Error(w, "missing instance parameter", http.StatusBadRequest)
+			return
+		}
+		exporters, err := d.discover()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to discover passenger instances: %s", err), http.StatusInternalServerError)
+			return
+		}
+		e, ok := exporters[instance]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown passenger instance %q", instance), http.StatusNotFound)
+			return
+		}
+
+		info, err := e.debugStatus(r.Context())
+		serveStatus(w, info, err)
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestRegistryDir(t *testing.T, instances ...string) string {
+	dir, err := ioutil.TempDir("", "passenger_registry")
+	if err != nil {
+		t.Fatalf("failed to create registry dir: %v", err)
+	}
+	for _, instance := range instances {
+		if err := os.Mkdir(filepath.Join(dir, instanceDirPrefix+instance), 0755); err != nil {
+			t.Fatalf("failed to create instance dir: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestDiscoverInstances(t *testing.T) {
+	dir := newTestRegistryDir(t, "abc123", "def456")
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "unrelated"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+
+	instances, err := discoverInstances(dir)
+	if err != nil {
+		t.Fatalf("failed to discover instances: %v", err)
+	}
+	sort.Strings(instances)
+	if want, got := []string{"abc123", "def456"}, instances; !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect instances: wanted %v, got %v", want, got)
+	}
+}
+
+func TestInstanceDiscoverer(t *testing.T) {
+	dir := newTestRegistryDir(t, "abc123", "def456")
+	defer os.RemoveAll(dir)
+
+	d := newInstanceDiscoverer(dir, func(instance string) *Exporter {
+		return NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(),
+			WithConstLabels(prometheus.Labels{"passenger_instance": instance}),
+		)
+	})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(d)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	var instances []string
+	for _, mf := range families {
+		if mf.GetName() == "passenger_up" {
+			for _, m := range mf.Metric {
+				instances = append(instances, labelValue(m, "passenger_instance"))
+			}
+		}
+	}
+	sort.Strings(instances)
+	if want, got := []string{"abc123", "def456"}, instances; !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect instances: wanted %v, got %v", want, got)
+	}
+}
+
+func TestInstanceDiscovererDebugStatus(t *testing.T) {
+	dir := newTestRegistryDir(t, "abc123")
+	defer os.RemoveAll(dir)
+
+	d := newInstanceDiscoverer(dir, func(instance string) *Exporter {
+		return NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(),
+			WithConstLabels(prometheus.Labels{"passenger_instance": instance}),
+		)
+	})
+	for query, want := range map[string]int{
+		"":                 http.StatusBadRequest,
+		"?instance=def456": http.StatusNotFound,
+		"?instance=abc123": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		d.debugStatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/status"+query, nil))
+		if want != rec.Code {
+			t.Fatalf("%q: incorrect status code: wanted %d, got %d", query, want, rec.Code)
+		}
+	}
+}
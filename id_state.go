@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/prometheus/common/log"
+)
+
+// saveProcessIdentifiers writes the ids assigned to the processes of every
+// app to path, so that a restarted exporter can keep exporting each process
+// under its id.
+func (e *Exporter) saveProcessIdentifiers(path string) error {
+	e.mutex.Lock()
+	content, err := json.Marshal(e.processIdentifiers)
+	e.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding process ids: %s", err)
+	}
+
+	// Write to a temporary file first so that a crash can't leave a
+	// truncated state file behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("error writing process ids %q: %s", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing process ids %q: %s", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing process ids %q: %s", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing process ids %q: %s", path, err)
+	}
+	return nil
+}
+
+// loadProcessIdentifiers restores the ids assigned to processes saved to
+// path by saveProcessIdentifiers. A missing file leaves them unassigned.
+func (e *Exporter) loadProcessIdentifiers(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading process ids %q: %s", path, err)
+	}
+
+	ids := make(map[string]map[string]int)
+	if err := json.Unmarshal(content, &ids); err != nil {
+		return fmt.Errorf("error parsing process ids %q: %s", path, err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.processIdentifiers = ids
+	return nil
+}
+
+// saveProcessIdentifiersOnShutdown saves the process ids of e to path when
+// the exporter receives SIGINT or SIGTERM, then exits.
+func saveProcessIdentifiersOnShutdown(e *Exporter, path string) {
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-shutdown
+		if err := e.saveProcessIdentifiers(path); err != nil {
+			log.Errorf("failed to save process ids: %s", err)
+			os.Exit(1)
+		}
+		log.Infof("saved process ids to %s on %s", path, sig)
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcessIdentifiersState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "id_state")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ids.json")
+
+	status := `<?xml version="1.0" encoding="iso8859-1" ?>
+<info version="3">
+  <passenger_version>5.0.26</passenger_version>
+  <max>4</max>
+  <supergroups>
+    <supergroup>
+      <name>/srv/app/first (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/first (production)</name>
+        <processes>
+          <process><pid>100</pid></process>
+          <process><pid>101</pid></process>
+        </processes>
+      </group>
+    </supergroup>
+    <supergroup>
+      <name>/srv/app/second (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/second (production)</name>
+        <processes>
+          <process><pid>200</pid></process>
+        </processes>
+      </group>
+    </supergroup>
+  </supergroups>
+</info>`
+	newExporter := func() *Exporter {
+		return NewExporterFromReader(func() (io.Reader, error) {
+			return strings.NewReader(status), nil
+		})
+	}
+
+	e := newExporter()
+	if err := e.loadProcessIdentifiers(path); err != nil {
+		t.Fatalf("unexpected error for missing state file: %v", err)
+	}
+	gatherFamily(t, e, "passenger_up")
+	if err := e.saveProcessIdentifiers(path); err != nil {
+		t.Fatalf("failed to save process ids: %v", err)
+	}
+
+	restarted := newExporter()
+	if err := restarted.loadProcessIdentifiers(path); err != nil {
+		t.Fatalf("failed to load process ids: %v", err)
+	}
+	if len(e.processIdentifiers) != 2 || !reflect.DeepEqual(e.processIdentifiers, restarted.processIdentifiers) {
+		t.Fatalf("process ids not restored: wanted %v, got %v", e.processIdentifiers, restarted.processIdentifiers)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0666); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+	if err := restarted.loadProcessIdentifiers(path); err == nil {
+		t.Fatal("expected error for corrupt state file")
+	}
+}
@@ -2,16 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"encoding/xml"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html/charset"
@@ -24,21 +33,65 @@ import (
 // Info represents the info section of passenger's status.
 type Info struct {
 	PassengerVersion         string       `xml:"passenger_version"`
+	InstanceID               string       `xml:"instance_id"`
 	AppCount                 string       `xml:"group_count"`
 	CurrentProcessCount      string       `xml:"process_count"`
 	MaxProcessCount          string       `xml:"max"`
 	CapacityUsed             string       `xml:"capacity_used"`
 	TopLevelRequestQueueSize string       `xml:"get_wait_list_size"`
 	SuperGroups              []SuperGroup `xml:"supergroups>supergroup"`
+
+	// outputBytes is the size of the status output the info was parsed from.
+	outputBytes int
+	// fetched is when the status was fetched, at which its processes are
+	// sampled for rates of change.
+	fetched time.Time
 }
 
 // SuperGroup represents the super group section of passenger's status.
 type SuperGroup struct {
-	Name             string `xml:"name"`
-	State            string `xml:"state"`
-	RequestQueueSize string `xml:"get_wait_list_size"`
-	CapacityUsed     string `xml:"capacity_used"`
-	Group            Group  `xml:"group"`
+	Name             string  `xml:"name"`
+	State            string  `xml:"state"`
+	RequestQueueSize string  `xml:"get_wait_list_size"`
+	CapacityUsed     string  `xml:"capacity_used"`
+	Groups           []Group `xml:"group"`
+}
+
+// appName returns the name label of group, an app of sg. It is the name of
+// the supergroup for its default group, and for any other group that name
+// suffixed by the group's component, as passenger names them. Groups whose
+// component is unnamed or shared with another group, such as old and new
+// groups coexisting, are told apart by their UUID.
+func appName(sg SuperGroup, group Group) string {
+	if group.Default == "true" || len(sg.Groups) == 1 {
+		return sg.Name
+	}
+
+	name := sg.Name + "#" + group.ComponentName
+	var shared int
+	for _, other := range sg.Groups {
+		if other.Default != "true" && other.ComponentName == group.ComponentName {
+			shared++
+		}
+	}
+	if group.ComponentName == "" || shared > 1 {
+		name += "@" + group.UUID
+	}
+	return name
+}
+
+// defaultGroup returns the default group of sg, or its first group if none
+// is marked as the default.
+func defaultGroup(sg SuperGroup) (Group, bool) {
+	for _, group := range sg.Groups {
+		if group.Default == "true" {
+			return group, true
+		}
+	}
+	if len(sg.Groups) == 0 {
+		return Group{}, false
+	}
+	return sg.Groups[0], true
 }
 
 // Group represents the group section of passenger's status.
@@ -98,6 +151,7 @@ type Process struct {
 // Options represents the options section of passenger's status.
 type Options struct {
 	AppRoot                   string `xml:"app_root"`
+	RestartDirectory          string `xml:"restart_dir"`
 	AppGroupName              string `xml:"app_group_name"`
 	AppType                   string `xml:"app_type"`
 	StartCommand              string `xml:"start_command"`
@@ -115,189 +169,2056 @@ type Options struct {
 	PythonBinPath             string `xml:"python"`
 	NodeJSBinPath             string `xml:"nodejs"`
 	USTRouterAddress          string `xml:"ust_router_address"`
-	USTRouterUsername         string `xml:"ust_router_username"`
-	USTRouterPassword         string `xml:"ust_router_password"`
+	USTRouterUsername         string `xml:"ust_router_username"` // Secret, redacted from /debug/status.
+	USTRouterPassword         string `xml:"ust_router_password"` // Secret, redacted from /debug/status.
 	Debugger                  string `xml:"debugger"`
 	Analytics                 string `xml:"analytics"`
-	APIKey                    string `xml:"api_key"`
+	APIKey                    string `xml:"api_key"` // Secret, only its presence is exported.
 	MinProcesses              string `xml:"min_processes"`
 	MaxProcesses              string `xml:"max_processes"`
 	MaxPreloaderIdleTime      string `xml:"max_preloader_idle_time"`
 	MaxOutOfBandWorkInstances string `xml:"max_out_of_band_work_instances"`
+	StickySessionCookieAttrs  string `xml:"sticky_sessions_cookie_attributes"`
 }
 
 const (
-	namespace            = "passenger"
-	nanosecondsPerSecond = 1000000000
+	namespace             = "passenger"
+	nanosecondsPerSecond  = 1000000000
+	microsecondsPerSecond = 1000000
+
+	// Passenger reports memory in kilobytes.
+	bytesPerKilobyte = 1024
 )
 
-var (
-	processIdentifiers = make(map[string]int)
+// Strategies for deriving the id label of process metrics.
+const (
+	// idStrategyBucket reuses the ids of replaced processes, see
+	// updateProcesses.
+	idStrategyBucket = "bucket"
+	// idStrategyGUPIDHash hashes passenger's globally unique process id.
+	idStrategyGUPIDHash = "gupid-hash"
 )
 
+// bucket identifies a process slot of an app, as assigned by
+// updateProcesses.
+type bucket struct {
+	name string
+	id   int
+}
+
 // Exporter collects metrics from passenger.
 type Exporter struct {
+	mutex sync.Mutex
+
+	// source of passenger's XML status output, which should give up once
+	// the context is done.
+	source func(context.Context) (io.Reader, error)
+
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
 	// binary file path for querying passenger state.
 	cmd  string
 	args []string
 
-	// Passenger command timeout.
+	// Working directory and additional environment for the passenger command.
+	dir string
+	env []string
+
+	// Timeout of the source, either the passenger command or the status URL.
 	timeout time.Duration
 
+	// Delay, jittered by up to half either way, before fetching passenger's
+	// status again after it was truncated.
+	truncatedRetryDelay time.Duration
+
+	// Overall deadline for obtaining passenger's status during a scrape, and
+	// the number of scrapes which exceeded it.
+	collectTimeout  time.Duration
+	collectTimeouts float64
+
+	// Number of scrapes in progress, including those waiting for another
+	// to finish.
+	inflightScrapes int32
+
+	// Nanoseconds the last run of the passenger command was waited on,
+	// stored atomically as abandoned runs may still finish during a scrape.
+	commandWait int64
+	// Size of the stderr output of the last run of the passenger command to
+	// exit, stored atomically for the same reason.
+	commandStderr int64
+
+	// Requests being served by the metrics handler, whose disconnection
+	// cancels fetching passenger's status. Nil when not served over HTTP.
+	scrapes *scrapeTracker
+
+	// Scrapes within minScrapeInterval of the last one are served its
+	// metrics, protecting passenger from scrape storms.
+	minScrapeInterval time.Duration
+	scrapeMutex       sync.Mutex
+	lastScrape        time.Time
+	lastMetrics       []prometheus.Metric
+
+	// Whether scrapes are served the status last fetched by startPolling,
+	// rather than fetching it themselves.
+	polling    bool
+	pollMutex  sync.Mutex
+	polledInfo *Info
+	polledErr  error
+
+	// Labels added to every metric.
+	constLabels prometheus.Labels
+
+	// Names of the metrics to export, all when nil, and the descriptors of
+	// those which are not.
+	enabledMetrics map[string]bool
+	disabledDescs  map[*prometheus.Desc]bool
+	metricNames    map[string]bool
+
+	// Help text replacing the built-in help of metrics by name.
+	helpOverrides map[string]string
+
+	// Whether to name app and process metrics with "app" and "process"
+	// subsystems rather than the legacy names.
+	subsystems bool
+
+	// Whether passenger_up ignores failures to parse passenger's status,
+	// which are then reported by passenger_parse_success instead.
+	upIgnoresParseErrors bool
+
+	// Whether failed scrapes export the metrics of the last successfully
+	// fetched status alongside passenger_up, which is kept here.
+	persistOnFailure bool
+	lastInfo         *Info
+
+	// Status last served by the debug status endpoint.
+	debugInfo *Info
+
+	// Whether to label process metrics with passenger's GUPID.
+	gupidLabel bool
+
+	// Apps to export process metrics for. All apps when empty.
+	detailedApps map[string]bool
+
+	// Whether to label app metrics with the app's base URI.
+	baseURILabel bool
+
+	// Whether to label app and process metrics with the app's environment.
+	environmentLabel bool
+
+	// Whether to export the process title of each app.
+	processTitleInfo bool
+
+	// Whether to export the spawn durations of each app's processes as a
+	// histogram rather than as gauges of their minimum, maximum and average.
+	spawnHistogram bool
+
+	// Whether to export whether a restart.txt is present in each app's
+	// restart directory.
+	restartTxt bool
+
+	// Whether to include the app's start command in its startup info.
+	startCommandLabel bool
+
+	// Highest app request queue size seen, and whether to reset it after
+	// every scrape.
+	appQueueMax      map[string]float64
+	appQueueMaxReset bool
+
+	// Number of processes of each app at the previous scrape.
+	appProcessCounts map[string]int
+
+	// Processes whose spawn duration has been observed, by gupid, and the
+	// labels of the apps observed for.
+	spawnObserved map[string]bool
+	spawnLabels   map[string][]string
+
+	// Minimum uptime for a process that never served a request to count as
+	// idle.
+	idleMinUptime time.Duration
+
+	// Number of each app's processes with the most memory to export memory
+	// metrics for, the others being summed up. All when zero.
+	topMemoryProcesses int
+
+	// Age beyond which processes are counted as overdue for a restart.
+	// Disabled when zero.
+	maxProcessAge time.Duration
+
+	// How to derive the id label of process metrics, one of the idStrategy
+	// constants.
+	idStrategy string
+
+	// Bucket assigned to each PID of each app, see updateProcesses.
+	processIdentifiers map[string]map[string]int
+
+	// PID last seen in each bucket, the number of times it changed and when
+	// it was first seen.
+	bucketPIDs      map[bucket]string
+	bucketRestarts  map[bucket]float64
+	bucketFirstSeen map[bucket]time.Time
+
+	// Requests processed by and memory of each bucket's process at the
+	// previous scrape, when that was, and the rates of change since the one
+	// before, which are exported again when replaying a cached status.
+	bucketRequests map[bucket]float64
+	bucketMemory   map[bucket]float64
+	bucketSampled  map[bucket]time.Time
+	bucketRate     map[bucket]float64
+	bucketGrowth   map[bucket]float64
+
+	// Whether to export when each bucket's process was first seen.
+	firstSeenMetric bool
+
+	// Type of passenger_requests_processed_total, counter unless it is
+	// exported untyped for scrapers mishandling its resets.
+	requestsProcessedType prometheus.ValueType
+
+	// Exporter metrics.
+	collectTimeoutsDesc *prometheus.Desc
+	inflightScrapesDesc *prometheus.Desc
+	commandWaitDesc     *prometheus.Desc
+	statusStderrBytes   *prometheus.Desc
+	parseSuccess        *prometheus.Desc
+
 	// Passenger metrics.
-	up                   *prometheus.Desc
-	version              *prometheus.Desc
-	topLevelRequestQueue *prometheus.Desc
-	maxProcessCount      *prometheus.Desc
-	currentProcessCount  *prometheus.Desc
-	appCount             *prometheus.Desc
+	up                       *prometheus.Desc
+	version                  *prometheus.Desc
+	instanceInfo             *prometheus.Desc
+	topLevelRequestQueue     *prometheus.Desc
+	capacityUsed             *prometheus.Desc
+	maxProcessCount          *prometheus.Desc
+	currentProcessCount      *prometheus.Desc
+	appCount                 *prometheus.Desc
+	appGroupCount            *prometheus.Desc
+	currentProcessesMismatch *prometheus.Desc
+	oldestProcess            *prometheus.Desc
+	timestampAnomalies       *prometheus.Desc
+	distinctRubies           *prometheus.Desc
+	distinctUsers            *prometheus.Desc
+	disableWaitList          *prometheus.Desc
+	disablingProcesses       *prometheus.Desc
+	rollingRestart           *prometheus.Desc
+	statusOutputBytes        *prometheus.Desc
+	appsByLifeStatus         *prometheus.Desc
 
 	// App metrics.
-	appRequestQueue  *prometheus.Desc
-	appProcsSpawning *prometheus.Desc
+	supergroupReady    *prometheus.Desc
+	supergroupByType   *prometheus.Desc
+	supergroupCapacity *prometheus.Desc
+	appRequestQueue    *prometheus.Desc
+	appCapacityUsed    *prometheus.Desc
+	appProcsByState    *prometheus.Desc
+	appProcsSpawning   *prometheus.Desc
+	appHeadroom        *prometheus.Desc
+	appIdleProcs       *prometheus.Desc
+	appStartupInfo     *prometheus.Desc
+	appAvgBusyness     *prometheus.Desc
+	appBusyFraction    *prometheus.Desc
+	appProcessTitle    *prometheus.Desc
+	appSessions        *prometheus.Desc
+	appStuckSpawning   *prometheus.Desc
+	appUtilization     *prometheus.Desc
+	appDisabledBusy    *prometheus.Desc
+	appCPU             *prometheus.Desc
+	appSwapping        *prometheus.Desc
+	appSwap            *prometheus.Desc
+	appTotalDemand     *prometheus.Desc
+	appOverMaxAge      *prometheus.Desc
+	appSinceSpawn      *prometheus.Desc
+	appOldestProc      *prometheus.Desc
+	appNewestProc      *prometheus.Desc
+	appRestartInfo     *prometheus.Desc
+	appRestartTxt      *prometheus.Desc
+	appHasAPIKey       *prometheus.Desc
+	appDebugger        *prometheus.Desc
+	appSpawnDuration   *prometheus.HistogramVec
+	appSpawnMin        *prometheus.Desc
+	appSpawnMax        *prometheus.Desc
+	appSpawnAvg        *prometheus.Desc
+	appSurgeProcs      *prometheus.Desc
+	appInterpreterInfo *prometheus.Desc
+	appMaxOOBWork      *prometheus.Desc
+	appCapPressure     *prometheus.Desc
+	appInconsistent    *prometheus.Desc
+	appSpawnerGens     *prometheus.Desc
+	appUnmappedProcs   *prometheus.Desc
+	appOtherMemory     *prometheus.Desc
+	appByConcurrency   *prometheus.Desc
+	appStickyCookie    *prometheus.Desc
+	appProcsChanged    *prometheus.Desc
+	appRequestQueueMax *prometheus.Desc
 
 	// Process metrics.
 	requestsProcessed *prometheus.Desc
 	procStartTime     *prometheus.Desc
 	procMemory        *prometheus.Desc
+	procMemEfficiency *prometheus.Desc
+	procPSS           *prometheus.Desc
+	procSwap          *prometheus.Desc
+	procPrivateDirty  *prometheus.Desc
+	procVMSize        *prometheus.Desc
+	procCPU           *prometheus.Desc
+	procSessions      *prometheus.Desc
+	procConcurrency   *prometheus.Desc
+	procBusyness      *prometheus.Desc
+	procRestarts      *prometheus.Desc
+	procFirstSeen     *prometheus.Desc
+	requestRate       *prometheus.Desc
+	memoryGrowth      *prometheus.Desc
+	trackedProcesses  *prometheus.Desc
+}
+
+// ExporterOption configures optional behaviour of an Exporter.
+type ExporterOption func(*Exporter)
+
+// WithCommandDir sets the working directory of the passenger command.
+func WithCommandDir(dir string) ExporterOption {
+	return func(e *Exporter) {
+		e.dir = dir
+	}
+}
+
+// WithCommandArgs passes args to the passenger command, which is then taken
+// to be the binary alone so that it may contain spaces.
+func WithCommandArgs(args []string) ExporterOption {
+	return func(e *Exporter) {
+		if len(args) > 0 {
+			e.args = args
+		}
+	}
+}
+
+// WithCommandEnv adds key=value pairs to the environment of the passenger
+// command. The exporter's own environment is inherited.
+func WithCommandEnv(env []string) ExporterOption {
+	return func(e *Exporter) {
+		e.env = env
+	}
+}
+
+// WithCollectTimeout bounds how long a scrape waits for passenger's status,
+// regardless of the source. A zero duration disables the deadline.
+func WithCollectTimeout(d time.Duration) ExporterOption {
+	return func(e *Exporter) {
+		e.collectTimeout = d
+	}
+}
+
+// WithScrapeTracker cancels fetching passenger's status once every scrape
+// tracked by t is gone, e.g. because Prometheus timed out and disconnected.
+func WithScrapeTracker(t *scrapeTracker) ExporterOption {
+	return func(e *Exporter) {
+		e.scrapes = t
+	}
+}
+
+// WithMinScrapeInterval serves scrapes arriving within d of the previous one
+// the metrics of that scrape, rather than querying passenger again.
+func WithMinScrapeInterval(d time.Duration) ExporterOption {
+	return func(e *Exporter) {
+		e.minScrapeInterval = d
+	}
+}
+
+// WithConstLabels adds labels with fixed values to every metric.
+func WithConstLabels(labels prometheus.Labels) ExporterOption {
+	return func(e *Exporter) {
+		if e.constLabels == nil {
+			e.constLabels = make(prometheus.Labels, len(labels))
+		}
+		for name, value := range labels {
+			e.constLabels[name] = value
+		}
+	}
+}
+
+// WithEnabledMetrics restricts the exported metrics to those with the given
+// names. An empty list exports all metrics.
+func WithEnabledMetrics(names []string) ExporterOption {
+	return func(e *Exporter) {
+		if len(names) == 0 {
+			e.enabledMetrics = nil
+			return
+		}
+		e.enabledMetrics = make(map[string]bool)
+		for _, name := range names {
+			e.enabledMetrics[name] = true
+		}
+	}
+}
+
+// WithHelpOverrides replaces the help text of the metrics with the given
+// names. Other metrics keep their built-in help.
+func WithHelpOverrides(help map[string]string) ExporterOption {
+	return func(e *Exporter) {
+		e.helpOverrides = help
+	}
+}
+
+// WithSubsystems names app and process metrics passenger_app_* and
+// passenger_process_* respectively, instead of the legacy names.
+func WithSubsystems(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.subsystems = enabled
+	}
+}
+
+// WithFirstSeenMetric enables passenger_proc_first_seen_timestamp_seconds,
+// the time each bucket's process was first seen.
+func WithFirstSeenMetric(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.firstSeenMetric = enabled
+	}
+}
+
+// WithRequestsProcessedUntyped exports passenger_requests_processed_total as
+// untyped rather than as a counter, for scrapers which mishandle the counter
+// resetting when a bucket's process is replaced.
+func WithRequestsProcessedUntyped(untyped bool) ExporterOption {
+	return func(e *Exporter) {
+		if untyped {
+			e.requestsProcessedType = prometheus.UntypedValue
+		}
+	}
+}
+
+// WithTopMemoryProcesses exports the memory metrics of only the n processes
+// of each app using the most memory, summing up the memory of the others in
+// passenger_app_other_processes_memory. Zero exports them for all processes.
+func WithTopMemoryProcesses(n int) ExporterOption {
+	return func(e *Exporter) {
+		e.topMemoryProcesses = n
+	}
+}
+
+// WithSpawnDurationHistogram exports passenger_app_spawn_duration_seconds as
+// a histogram over spawnDurationBuckets rather than as gauges of the
+// minimum, maximum and average spawn durations.
+func WithSpawnDurationHistogram(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.spawnHistogram = enabled
+	}
+}
+
+// WithIDStrategy sets how the id label of process metrics is derived, either
+// idStrategyBucket, the default, or idStrategyGUPIDHash.
+func WithIDStrategy(strategy string) ExporterOption {
+	return func(e *Exporter) {
+		e.idStrategy = strategy
+	}
+}
+
+// WithGUPIDLabel adds passenger's globally unique process id as a "gupid"
+// label on process metrics.
+func WithGUPIDLabel(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.gupidLabel = enabled
+	}
+}
+
+// WithDetailedApps restricts process metrics to the named apps. Other apps
+// only get app level metrics. An empty list exports process metrics for all
+// apps.
+func WithDetailedApps(names []string) ExporterOption {
+	return func(e *Exporter) {
+		e.detailedApps = make(map[string]bool)
+		for _, name := range names {
+			e.detailedApps[name] = true
+		}
+	}
+}
+
+// WithBaseURILabel adds the app's base URI as a "base_uri" label on app
+// metrics, to tell apart apps mounted at different sub-URIs.
+func WithBaseURILabel(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.baseURILabel = enabled
+	}
+}
+
+// WithEnvironmentLabel adds the app's environment as an "environment" label
+// on app and process metrics.
+func WithEnvironmentLabel(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.environmentLabel = enabled
+	}
+}
+
+// WithUpIgnoresParseErrors keeps passenger_up at 1 when passenger's status
+// cannot be parsed, exporting passenger_parse_success instead.
+func WithUpIgnoresParseErrors(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.upIgnoresParseErrors = enabled
+	}
+}
+
+// WithPersistOnFailure exports the metrics of the last status fetched when
+// fetching passenger's status fails, keeping series continuous through
+// transient failures while passenger_up still reports them.
+func WithPersistOnFailure(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.persistOnFailure = enabled
+	}
+}
+
+// WithProcessTitleInfo enables the passenger_app_process_title info metric.
+func WithProcessTitleInfo(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.processTitleInfo = enabled
+	}
+}
+
+// WithRestartTxt enables passenger_app_restart_txt_present, which requires
+// the exporter to be able to stat each app's restart directory.
+func WithRestartTxt(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.restartTxt = enabled
+	}
+}
+
+// WithStartCommandLabel adds the app's start command as a "start_command"
+// label on its startup info.
+func WithStartCommandLabel(enabled bool) ExporterOption {
+	return func(e *Exporter) {
+		e.startCommandLabel = enabled
+	}
+}
+
+// WithAppRequestQueueMaxReset controls whether the app request queue
+// high-water mark is reset after every scrape rather than kept for the
+// lifetime of the exporter.
+func WithAppRequestQueueMaxReset(reset bool) ExporterOption {
+	return func(e *Exporter) {
+		e.appQueueMaxReset = reset
+	}
+}
+
+// WithIdleProcessMinUptime sets how long a process must have been up without
+// serving a request before it is counted as idle.
+func WithIdleProcessMinUptime(d time.Duration) ExporterOption {
+	return func(e *Exporter) {
+		e.idleMinUptime = d
+	}
+}
+
+// WithMaxProcessAge enables passenger_app_processes_over_max_age, counting
+// processes which have been up for longer than d.
+func WithMaxProcessAge(d time.Duration) ExporterOption {
+	return func(e *Exporter) {
+		e.maxProcessAge = d
+	}
+}
+
+// NewExporter returns an initialized exporter which runs cmd to query
+// passenger's status. Unless WithCommandArgs is given, cmd is split on spaces
+// into the binary and its arguments.
+func NewExporter(cmd string, timeout float64, opts ...ExporterOption) *Exporter {
+	e := &Exporter{
+		cmd:     cmd,
+		timeout: time.Duration(timeout * nanosecondsPerSecond),
+	}
+	e.source = e.command
+	e.init(opts)
+
+	if e.args == nil {
+		cmdComponents := strings.Split(cmd, " ")
+		e.cmd, e.args = cmdComponents[0], cmdComponents[1:]
+	}
+	return e
+}
+
+// NewExporterFromReader returns an initialized exporter which reads
+// passenger's XML status from the reader returned by source on every scrape.
+// Readers implementing io.Closer are closed once parsed.
+func NewExporterFromReader(source func() (io.Reader, error), opts ...ExporterOption) *Exporter {
+	e := &Exporter{
+		source: func(context.Context) (io.Reader, error) {
+			return source()
+		},
+	}
+	return e.init(opts)
+}
+
+// NewExporterFromURL returns an initialized exporter which fetches
+// passenger's XML status from url, e.g. a mock server in integration tests,
+// giving up after timeout seconds.
+func NewExporterFromURL(url string, timeout float64, opts ...ExporterOption) *Exporter {
+	e := &Exporter{timeout: time.Duration(timeout * nanosecondsPerSecond)}
+	client := &http.Client{Timeout: e.timeout}
+	e.source = func(ctx context.Context) (io.Reader, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %q fetching %s", resp.Status, url)
+		}
+		return resp.Body, nil
+	}
+	return e.init(opts)
+}
+
+func (e *Exporter) init(opts []ExporterOption) *Exporter {
+	e.now = time.Now
+	e.truncatedRetryDelay = 100 * time.Millisecond
+	e.requestsProcessedType = prometheus.CounterValue
+	e.processIdentifiers = make(map[string]map[string]int)
+	e.appQueueMax = make(map[string]float64)
+	e.appProcessCounts = make(map[string]int)
+	e.spawnObserved = make(map[string]bool)
+	e.spawnLabels = make(map[string][]string)
+	e.bucketPIDs = make(map[bucket]string)
+	e.bucketRestarts = make(map[bucket]float64)
+	e.bucketFirstSeen = make(map[bucket]time.Time)
+	e.bucketRequests = make(map[bucket]float64)
+	e.bucketMemory = make(map[bucket]float64)
+	e.bucketSampled = make(map[bucket]time.Time)
+	e.bucketRate = make(map[bucket]float64)
+	e.bucketGrowth = make(map[bucket]float64)
+	e.disabledDescs = make(map[*prometheus.Desc]bool)
+	e.metricNames = make(map[string]bool)
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	// Legacy metric names share the namespace's empty subsystem.
+	appSubsystem, appPrefix := "", "app_"
+	procSubsystem, procPrefix := "", "proc_"
+	if e.subsystems {
+		appSubsystem, appPrefix = "app", ""
+		procSubsystem, procPrefix = "process", ""
+	}
+
+	appLabels := []string{"name"}
+	if e.baseURILabel {
+		appLabels = append(appLabels, "base_uri")
+	}
+	if e.environmentLabel {
+		appLabels = append(appLabels, "environment")
+	}
+
+	startupLabels := append([]string{}, appLabels...)
+	startupLabels = append(startupLabels, "startup_file")
+	if e.startCommandLabel {
+		startupLabels = append(startupLabels, "start_command")
+	}
+
+	processTitleLabels := append([]string{}, appLabels...)
+	processTitleLabels = append(processTitleLabels, "process_title")
+
+	restartLabels := append([]string{}, appLabels...)
+	restartLabels = append(restartLabels, "restart_dir")
+
+	interpreterLabels := append([]string{}, appLabels...)
+	interpreterLabels = append(interpreterLabels, "ruby", "python", "nodejs")
+
+	concurrencyLabels := append([]string{}, appLabels...)
+	concurrencyLabels = append(concurrencyLabels, "concurrency")
+
+	appTypeLabels := append([]string{}, appLabels...)
+	appTypeLabels = append(appTypeLabels, "app_type")
+
+	stateLabels := append([]string{}, appLabels...)
+	stateLabels = append(stateLabels, "state")
+
+	stickyCookieLabels := append([]string{}, appLabels...)
+	stickyCookieLabels = append(stickyCookieLabels, "attributes")
+
+	trackedLabels := []string{"name"}
+	bucketLabels := []string{"name", "id"}
+	if e.environmentLabel {
+		trackedLabels = append(trackedLabels, "environment")
+		bucketLabels = append(bucketLabels, "environment")
+	}
+
+	procLabels := append([]string{}, bucketLabels...)
+	if e.gupidLabel {
+		procLabels = append(procLabels, "gupid")
+	}
+
+	e.collectTimeoutsDesc = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "collect_timeouts_total"),
+		"Number of scrapes which timed out waiting for passenger's status.",
+		nil,
+	)
+	e.inflightScrapesDesc = e.newDesc(
+		prometheus.BuildFQName(namespace, "exporter", "inflight_scrapes"),
+		"Number of scrapes in progress.",
+		nil,
+	)
+	e.commandWaitDesc = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "command_wait_seconds"),
+		"Time the last run of passenger.command was waited on before exiting or timing out.",
+		nil,
+	)
+	e.statusStderrBytes = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "status_stderr_bytes"),
+		"Size in bytes of the stderr output, such as warnings, of the last run of passenger.command to exit.",
+		nil,
+	)
+	e.parseSuccess = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "parse_success"),
+		"Whether passenger's status could be parsed.",
+		nil,
+	)
+	e.up = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Current health of passenger.",
+		nil,
+	)
+	e.version = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "version"),
+		"Version of passenger.",
+		[]string{"version"},
+	)
+	e.instanceInfo = e.newDesc(
+		prometheus.BuildFQName(namespace, "instance", "info"),
+		"Unique id of the passenger instance, when reported by passenger.",
+		[]string{"uuid"},
+	)
+	e.topLevelRequestQueue = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "top_level_request_queue"),
+		"Number of requests in the top-level queue.",
+		nil,
+	)
+	e.capacityUsed = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "capacity_used"),
+		"Capacity used by all apps, to compare with the maximum number of processes.",
+		nil,
+	)
+	e.maxProcessCount = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "max_processes"),
+		"Configured maximum number of processes.",
+		nil,
+	)
+	e.currentProcessCount = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "current_processes"),
+		"Current number of processes.",
+		nil,
+	)
+	e.appCount = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "app_count"),
+		"Number of apps.",
+		nil,
+	)
+	e.appGroupCount = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "app_group_count"),
+		"Number of app groups listed in passenger's status, 0 while no apps are deployed.",
+		nil,
+	)
+	e.currentProcessesMismatch = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "current_processes_mismatch"),
+		"Number of processes listed across all apps minus passenger's reported process count.",
+		nil,
+	)
+	e.oldestProcess = e.newDesc(
+		prometheus.BuildFQName(namespace, "instance", "oldest_process_seconds"),
+		"Seconds since the oldest process or spawner across all apps was created.",
+		nil,
+	)
+	e.timestampAnomalies = e.newDesc(
+		prometheus.BuildFQName(namespace, "instance", "timestamp_anomalies"),
+		"Number of processes last used before they finished spawning or with timestamps in the future, indicating clock skew.",
+		nil,
+	)
+	e.distinctRubies = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "distinct_ruby_versions"),
+		"Number of distinct Ruby interpreter paths used across all apps.",
+		nil,
+	)
+	e.distinctUsers = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "distinct_app_users"),
+		"Number of distinct users apps run as.",
+		nil,
+	)
+	e.disableWaitList = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "disable_wait_list_total"),
+		"Number of requests waiting for processes to be disabled across all apps.",
+		nil,
+	)
+	e.disablingProcesses = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "disabling_processes_total"),
+		"Number of processes being disabled across all apps.",
+		nil,
+	)
+	e.rollingRestart = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "rolling_restart_active"),
+		"Whether any app is restarting or disabling processes, as during a deploy.",
+		nil,
+	)
+	e.statusOutputBytes = e.newDesc(
+		prometheus.BuildFQName(namespace, "status", "output_bytes"),
+		"Size of passenger's status output in bytes.",
+		nil,
+	)
+	e.appsByLifeStatus = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "apps_by_life_status"),
+		"Number of apps in each life status.",
+		[]string{"life_status"},
+	)
+	e.supergroupReady = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "supergroup_ready"),
+		"Whether an app's supergroup is in the READY state.",
+		appLabels,
+	)
+	e.supergroupCapacity = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "supergroup_capacity_used"),
+		"Capacity used by an app's supergroup.",
+		appLabels,
+	)
+	e.supergroupByType = e.newDesc(
+		prometheus.BuildFQName(namespace, "supergroup", "processes_by_app_type"),
+		"Number of processes across the groups of an app's supergroup of each app type.",
+		appTypeLabels,
+	)
+	e.appRequestQueue = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"request_queue"),
+		"Number of requests in the app queue.",
+		appLabels,
+	)
+	e.appCapacityUsed = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"capacity_used"),
+		"Capacity used by an app.",
+		appLabels,
+	)
+	e.appProcsByState = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"processes"),
+		"Number of an app's processes which are enabled, being disabled, or disabled.",
+		stateLabels,
+	)
+	e.appProcsSpawning = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"procs_spawning"),
+		"Number of processes spawning.",
+		appLabels,
+	)
+	e.appRequestQueueMax = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"request_queue_max"),
+		"Highest number of requests seen in the app queue.",
+		appLabels,
+	)
+	e.appHeadroom = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"process_headroom"),
+		"Number of additional processes an app can spawn before reaching its own or passenger's maximum.",
+		appLabels,
+	)
+	e.appIdleProcs = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"idle_processes"),
+		"Number of processes that have not served a request since spawning.",
+		appLabels,
+	)
+	e.appStartupInfo = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"startup_info"),
+		"Startup file, and optionally command, used to boot an app.",
+		startupLabels,
+	)
+	e.appAvgBusyness = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"avg_busyness"),
+		"Average busyness of an app's processes.",
+		appLabels,
+	)
+	e.appBusyFraction = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"busy_process_fraction"),
+		"Fraction of an app's processes with at least one active session.",
+		appLabels,
+	)
+	e.appProcessTitle = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"process_title"),
+		"Title passenger gives an app's processes.",
+		processTitleLabels,
+	)
+	e.appSessions = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"sessions_total"),
+		"Number of sessions open across an app's processes.",
+		appLabels,
+	)
+	e.appStuckSpawning = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"stuck_spawning_processes"),
+		"Number of processes which have been spawning for longer than the app's start timeout.",
+		appLabels,
+	)
+	e.appUtilization = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"process_utilization"),
+		"Ratio of an app's enabled processes to its maximum number of processes.",
+		appLabels,
+	)
+	e.appDisabledBusy = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"disabled_with_sessions"),
+		"Number of disabled processes which still have open sessions.",
+		appLabels,
+	)
+	e.appCPU = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"cpu_total"),
+		"Sum of the CPU usage percentage of the app's processes.",
+		appLabels,
+	)
+	e.appSwapping = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"processes_swapping"),
+		"Number of an app's processes with memory swapped out.",
+		appLabels,
+	)
+	e.appSwap = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"swap_bytes"),
+		"Memory of an app's processes swapped out in bytes.",
+		appLabels,
+	)
+	e.appTotalDemand = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"total_demand"),
+		"Number of requests queued or being served by the app's processes.",
+		appLabels,
+	)
+	e.appOverMaxAge = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"processes_over_max_age"),
+		"Number of processes up for longer than the configured maximum age.",
+		appLabels,
+	)
+	e.appSinceSpawn = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"time_since_last_spawn_seconds"),
+		"Seconds since an app's most recently spawned process finished spawning.",
+		appLabels,
+	)
+	e.appOldestProc = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"oldest_process_seconds"),
+		"Seconds since an app's oldest live process finished spawning.",
+		appLabels,
+	)
+	e.appNewestProc = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"newest_process_seconds"),
+		"Seconds since an app's newest live process finished spawning.",
+		appLabels,
+	)
+	e.appRestartInfo = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"restart_info"),
+		"Directory in which passenger watches for an app's restart.txt.",
+		restartLabels,
+	)
+	e.appRestartTxt = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"restart_txt_present"),
+		"Whether a restart.txt is present in an app's restart directory.",
+		appLabels,
+	)
+	e.appHasAPIKey = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"has_api_key"),
+		"Whether an API key is configured for an app.",
+		appLabels,
+	)
+	e.appDebugger = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"debugger_enabled"),
+		"Whether the passenger debugger is enabled for an app.",
+		appLabels,
+	)
+	e.appSpawnDuration = e.newHistogramVec(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"spawn_duration_seconds"),
+		"Time taken to spawn an app's processes, observed once for every process.",
+		appLabels,
+		spawnDurationBuckets,
+	)
+	e.appSpawnMin = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"spawn_duration_seconds_min"),
+		"Shortest time taken to spawn one of an app's current processes.",
+		appLabels,
+	)
+	e.appSpawnMax = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"spawn_duration_seconds_max"),
+		"Longest time taken to spawn one of an app's current processes.",
+		appLabels,
+	)
+	e.appSpawnAvg = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"spawn_duration_seconds_avg"),
+		"Average time taken to spawn an app's current processes.",
+		appLabels,
+	)
+	e.appSurgeProcs = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"surge_processes"),
+		"Number of processes an app is running beyond its maximum.",
+		appLabels,
+	)
+	e.appInterpreterInfo = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"interpreter_info"),
+		"Interpreter paths configured for an app, empty when not set.",
+		interpreterLabels,
+	)
+	e.appMaxOOBWork = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"max_oob_work_instances"),
+		"Maximum number of an app's processes which may do out-of-band work at once.",
+		appLabels,
+	)
+	e.appCapPressure = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"capacity_pressure"),
+		"Capacity used by an app minus its number of enabled processes.",
+		appLabels,
+	)
+	e.appInconsistent = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"inconsistent_state_processes"),
+		"Number of enabled processes which are no longer alive.",
+		appLabels,
+	)
+	e.appSpawnerGens = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"spawner_generations"),
+		"Number of distinct spawners an app's processes were spawned by. More than one means they may run different code.",
+		appLabels,
+	)
+	e.appUnmappedProcs = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"unmapped_processes"),
+		"Number of an app's processes without an id assigned, whose process metrics are dropped.",
+		appLabels,
+	)
+	e.appOtherMemory = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"other_processes_memory"),
+		"Memory consumed by an app's processes other than those using the most, which are exported individually.",
+		appLabels,
+	)
+	e.appByConcurrency = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"processes_by_concurrency"),
+		"Number of an app's processes with each concurrency.",
+		concurrencyLabels,
+	)
+	e.appStickyCookie = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"sticky_cookie_info"),
+		"Attributes of an app's sticky sessions cookie, exported while any are set.",
+		stickyCookieLabels,
+	)
+	e.appProcsChanged = e.newDesc(
+		prometheus.BuildFQName(namespace, appSubsystem, appPrefix+"process_count_changed"),
+		"Whether an app's number of processes changed since the previous scrape.",
+		appLabels,
+	)
+	e.requestsProcessed = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, "requests_processed_total"),
+		"Number of requests served by a process.",
+		procLabels,
+	)
+	e.procFirstSeen = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"first_seen_timestamp_seconds"),
+		"Unix time the process in a bucket was first seen.",
+		procLabels,
+	)
+	e.requestRate = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"requests_per_second"),
+		"Requests served per second by a process since the previous scrape.",
+		procLabels,
+	)
+	e.memoryGrowth = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_growth_bytes_per_second"),
+		"Change in a process's real memory per second since the previous scrape.",
+		procLabels,
+	)
+	e.procStartTime = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"start_time_seconds"),
+		"Unix time the process started spawning, in seconds.",
+		procLabels,
+	)
+	e.procMemory = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory"),
+		"Memory consumed by a process",
+		procLabels,
+	)
+	e.procMemEfficiency = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_efficiency"),
+		"Ratio of real memory to virtual memory size of a process.",
+		procLabels,
+	)
+	// Like procMemory, in the kilobytes passenger reports so that they can
+	// be compared with it directly.
+	e.procPSS = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_pss"),
+		"Proportional set size of a process in kilobytes, counting its share of shared memory.",
+		procLabels,
+	)
+	e.procSwap = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_swap"),
+		"Memory of a process swapped out in kilobytes.",
+		procLabels,
+	)
+	e.procPrivateDirty = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_private_dirty"),
+		"Private dirty memory of a process in kilobytes, which is not shared with other processes.",
+		procLabels,
+	)
+	e.procVMSize = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"memory_vmsize"),
+		"Virtual memory size of a process in kilobytes.",
+		procLabels,
+	)
+	e.procCPU = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"cpu"),
+		"CPU usage percentage of a process.",
+		procLabels,
+	)
+	e.procSessions = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"sessions"),
+		"Number of sessions a process is serving.",
+		procLabels,
+	)
+	e.procConcurrency = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"concurrency"),
+		"Maximum number of sessions a process can serve concurrently, 0 for unlimited.",
+		procLabels,
+	)
+	e.procBusyness = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"busyness"),
+		"Busyness of a process as used by passenger's load balancer.",
+		procLabels,
+	)
+	e.procRestarts = e.newDesc(
+		prometheus.BuildFQName(namespace, procSubsystem, procPrefix+"restarts_total"),
+		"Number of times the process occupying a bucket was replaced.",
+		bucketLabels,
+	)
+	e.trackedProcesses = e.newDesc(
+		prometheus.BuildFQName(namespace, "", "tracked_processes"),
+		"Number of processes of an app with an id assigned.",
+		trackedLabels,
+	)
+
+	for name := range e.enabledMetrics {
+		if !e.metricNames[name] {
+			log.Warnf("unknown metric %q in enabled metrics", name)
+		}
+	}
+	for name := range e.helpOverrides {
+		if !e.metricNames[name] {
+			log.Warnf("unknown metric %q in help overrides", name)
+		}
+	}
+	return e
+}
+
+// newDesc returns a descriptor carrying the exporter's constant labels,
+// recording it as disabled unless enabled by WithEnabledMetrics.
+func (e *Exporter) newDesc(fqName, help string, variableLabels []string) *prometheus.Desc {
+	if override, ok := e.helpOverrides[fqName]; ok {
+		help = override
+	}
+	desc := prometheus.NewDesc(fqName, help, variableLabels, e.constLabels)
+	if e.enabledMetrics != nil && !e.enabledMetrics[fqName] {
+		e.disabledDescs[desc] = true
+	}
+	e.metricNames[fqName] = true
+	return desc
+}
+
+// newHistogramVec returns a histogram partitioned by variableLabels, like
+// newDesc does a description.
+func (e *Exporter) newHistogramVec(fqName, help string, variableLabels []string, buckets []float64) *prometheus.HistogramVec {
+	if override, ok := e.helpOverrides[fqName]; ok {
+		help = override
+	}
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        fqName,
+		Help:        help,
+		ConstLabels: e.constLabels,
+		Buckets:     buckets,
+	}, variableLabels)
+	if e.enabledMetrics != nil && !e.enabledMetrics[fqName] {
+		descs := make(chan *prometheus.Desc, 1)
+		vec.Describe(descs)
+		e.disabledDescs[<-descs] = true
+	}
+	e.metricNames[fqName] = true
+	return vec
+}
+
+// Describe describes all the enabled metrics exported by the passenger
+// exporter.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		e.describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		if !e.disabledDescs[desc] {
+			ch <- desc
+		}
+	}
+}
+
+func (e *Exporter) describe(ch chan<- *prometheus.Desc) {
+	ch <- e.collectTimeoutsDesc
+	ch <- e.inflightScrapesDesc
+	ch <- e.commandWaitDesc
+	ch <- e.statusStderrBytes
+	ch <- e.parseSuccess
+	ch <- e.up
+	ch <- e.version
+	ch <- e.instanceInfo
+	ch <- e.topLevelRequestQueue
+	ch <- e.capacityUsed
+	ch <- e.maxProcessCount
+	ch <- e.currentProcessCount
+	ch <- e.appCount
+	ch <- e.appGroupCount
+	ch <- e.currentProcessesMismatch
+	ch <- e.oldestProcess
+	ch <- e.timestampAnomalies
+	ch <- e.distinctRubies
+	ch <- e.distinctUsers
+	ch <- e.disableWaitList
+	ch <- e.disablingProcesses
+	ch <- e.rollingRestart
+	ch <- e.statusOutputBytes
+	ch <- e.appsByLifeStatus
+	ch <- e.supergroupReady
+	ch <- e.supergroupByType
+	ch <- e.supergroupCapacity
+	ch <- e.appRequestQueue
+	ch <- e.appCapacityUsed
+	ch <- e.appProcsByState
+	ch <- e.appProcsSpawning
+	ch <- e.appRequestQueueMax
+	ch <- e.appHeadroom
+	ch <- e.appIdleProcs
+	ch <- e.appStartupInfo
+	ch <- e.appAvgBusyness
+	ch <- e.appBusyFraction
+	ch <- e.appProcessTitle
+	ch <- e.appSessions
+	ch <- e.appStuckSpawning
+	ch <- e.appUtilization
+	ch <- e.appDisabledBusy
+	ch <- e.appCPU
+	ch <- e.appSwapping
+	ch <- e.appSwap
+	ch <- e.appTotalDemand
+	ch <- e.appOverMaxAge
+	ch <- e.appSinceSpawn
+	ch <- e.appOldestProc
+	ch <- e.appNewestProc
+	ch <- e.appRestartInfo
+	ch <- e.appRestartTxt
+	ch <- e.appHasAPIKey
+	ch <- e.appDebugger
+	e.appSpawnDuration.Describe(ch)
+	ch <- e.appSpawnMin
+	ch <- e.appSpawnMax
+	ch <- e.appSpawnAvg
+	ch <- e.appSurgeProcs
+	ch <- e.appInterpreterInfo
+	ch <- e.appMaxOOBWork
+	ch <- e.appCapPressure
+	ch <- e.appInconsistent
+	ch <- e.appSpawnerGens
+	ch <- e.appUnmappedProcs
+	ch <- e.appOtherMemory
+	ch <- e.appByConcurrency
+	ch <- e.appStickyCookie
+	ch <- e.appProcsChanged
+	ch <- e.requestsProcessed
+	ch <- e.procStartTime
+	ch <- e.procMemory
+	ch <- e.procMemEfficiency
+	ch <- e.procPSS
+	ch <- e.procSwap
+	ch <- e.procPrivateDirty
+	ch <- e.procVMSize
+	ch <- e.procCPU
+	ch <- e.procSessions
+	ch <- e.procConcurrency
+	ch <- e.procBusyness
+	ch <- e.procRestarts
+	ch <- e.procFirstSeen
+	ch <- e.requestRate
+	ch <- e.memoryGrowth
+	ch <- e.trackedProcesses
+}
+
+// Collect fetches the statistics from passenger, and delivers the enabled ones
+// as Prometheus metrics.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	atomic.AddInt32(&e.inflightScrapes, 1)
+	defer atomic.AddInt32(&e.inflightScrapes, -1)
+
+	if len(e.disabledDescs) == 0 {
+		e.collectLimited(ch)
+		return
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		e.collectLimited(metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		if !e.disabledDescs[m.Desc()] {
+			ch <- m
+		}
+	}
+}
+
+// collectLimited collects, unless the last scrape was less than the minimum
+// scrape interval ago, in which case its metrics are delivered again.
+func (e *Exporter) collectLimited(ch chan<- prometheus.Metric) {
+	if e.minScrapeInterval <= 0 {
+		e.collect(ch)
+		return
+	}
+
+	e.scrapeMutex.Lock()
+	defer e.scrapeMutex.Unlock()
+
+	now := e.now()
+	if e.lastMetrics != nil && now.Sub(e.lastScrape) < e.minScrapeInterval {
+		for _, m := range e.lastMetrics {
+			ch <- m
+		}
+		return
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		e.collect(metrics)
+		close(metrics)
+	}()
+	var collected []prometheus.Metric
+	for m := range metrics {
+		collected = append(collected, m)
+		ch <- m
+	}
+	e.lastScrape, e.lastMetrics = now, collected
+}
+
+func (e *Exporter) collect(ch chan<- prometheus.Metric) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if e.scrapes != nil {
+		ctx, cancel = e.scrapes.context()
+	}
+	defer cancel()
+
+	info, err := e.latestStatus(ctx)
+	ch <- prometheus.MustNewConstMetric(e.collectTimeoutsDesc, prometheus.CounterValue, e.collectTimeouts)
+	ch <- prometheus.MustNewConstMetric(e.inflightScrapesDesc, prometheus.GaugeValue, float64(atomic.LoadInt32(&e.inflightScrapes)))
+	if e.cmd != "" {
+		wait := time.Duration(atomic.LoadInt64(&e.commandWait))
+		ch <- prometheus.MustNewConstMetric(e.commandWaitDesc, prometheus.GaugeValue, wait.Seconds())
+		ch <- prometheus.MustNewConstMetric(e.statusStderrBytes, prometheus.GaugeValue, float64(atomic.LoadInt64(&e.commandStderr)))
+	}
+	if err != nil {
+		if _, ok := err.(parseError); ok && e.upIgnoresParseErrors {
+			ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
+			ch <- prometheus.MustNewConstMetric(e.parseSuccess, prometheus.GaugeValue, 0)
+		} else {
+			ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
+		}
+		log.Errorf("failed to collect status from passenger: %s", err)
+		if !e.persistOnFailure || e.lastInfo == nil {
+			return
+		}
+		info = e.lastInfo
+	} else {
+		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
+		if e.upIgnoresParseErrors {
+			ch <- prometheus.MustNewConstMetric(e.parseSuccess, prometheus.GaugeValue, 1)
+		}
+	}
+	// Neither cached status replayed after a failure nor a polled status
+	// already collected is sampled again.
+	fresh := err == nil && info != e.lastInfo
+	e.lastInfo = info
+	ch <- prometheus.MustNewConstMetric(e.version, prometheus.GaugeValue, 1, info.PassengerVersion)
+	if info.InstanceID != "" {
+		ch <- prometheus.MustNewConstMetric(e.instanceInfo, prometheus.GaugeValue, 1, info.InstanceID)
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.topLevelRequestQueue, prometheus.GaugeValue, parseFloat(info.TopLevelRequestQueueSize))
+	ch <- prometheus.MustNewConstMetric(e.capacityUsed, prometheus.GaugeValue, parseFloat(info.CapacityUsed))
+	ch <- prometheus.MustNewConstMetric(e.maxProcessCount, prometheus.GaugeValue, parseFloat(info.MaxProcessCount))
+	ch <- prometheus.MustNewConstMetric(e.currentProcessCount, prometheus.GaugeValue, parseFloat(info.CurrentProcessCount))
+	ch <- prometheus.MustNewConstMetric(e.appCount, prometheus.GaugeValue, parseFloat(info.AppCount))
+
+	var listedProcesses, groups int
+	var disableWaitList, disabling, anomalies float64
+	rubies := make(map[string]bool)
+	users := make(map[string]bool)
+	lifeStatuses := make(map[string]float64)
+	for _, sg := range info.SuperGroups {
+		for _, group := range sg.Groups {
+			groups++
+			listedProcesses += len(group.Processes)
+			anomalies += float64(e.anomalousTimestamps(group.Processes))
+			lifeStatuses[group.LifeStatus]++
+			disableWaitList += parseFloat(group.DisableWaitListSize)
+			disabling += parseFloat(group.DisablingProcessCount)
+			if ruby := group.Options.RubyBinPath; ruby != "" {
+				rubies[ruby] = true
+			}
+			if group.User != "" {
+				users[group.User] = true
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.appGroupCount, prometheus.GaugeValue, float64(groups))
+	ch <- prometheus.MustNewConstMetric(e.currentProcessesMismatch, prometheus.GaugeValue, float64(listedProcesses)-parseFloat(info.CurrentProcessCount))
+	ch <- prometheus.MustNewConstMetric(e.distinctRubies, prometheus.GaugeValue, float64(len(rubies)))
+	ch <- prometheus.MustNewConstMetric(e.distinctUsers, prometheus.GaugeValue, float64(len(users)))
+	ch <- prometheus.MustNewConstMetric(e.disableWaitList, prometheus.GaugeValue, disableWaitList)
+	ch <- prometheus.MustNewConstMetric(e.disablingProcesses, prometheus.GaugeValue, disabling)
+	ch <- prometheus.MustNewConstMetric(e.timestampAnomalies, prometheus.GaugeValue, anomalies)
+	ch <- prometheus.MustNewConstMetric(e.rollingRestart, prometheus.GaugeValue, boolToFloat(disabling > 0 || lifeStatuses["RESTARTING"] > 0))
+	ch <- prometheus.MustNewConstMetric(e.statusOutputBytes, prometheus.GaugeValue, float64(info.outputBytes))
+	for lifeStatus, count := range lifeStatuses {
+		ch <- prometheus.MustNewConstMetric(e.appsByLifeStatus, prometheus.GaugeValue, count, lifeStatus)
+	}
+	if oldest, ok := oldestSpawnTime(info); ok {
+		ch <- prometheus.MustNewConstMetric(e.oldestProcess, prometheus.GaugeValue, e.now().Sub(oldest).Seconds())
+	}
+
+	// Passenger is up but idle while no apps are deployed, e.g. on a freshly
+	// provisioned host, leaving only the metrics above.
+	for _, sg := range info.SuperGroups {
+		e.collectSupergroup(ch, sg)
+		for _, group := range sg.Groups {
+			e.collectApp(ch, info, sg, group, fresh)
+		}
+	}
+	if fresh {
+		e.pruneBuckets(info)
+	}
+	if e.spawnHistogram {
+		e.appSpawnDuration.Collect(ch)
+	}
+}
+
+// appLabels returns the values of the labels of app metrics for group,
+// named name.
+func (e *Exporter) appLabels(name string, group Group) []string {
+	labels := []string{name}
+	if e.baseURILabel {
+		labels = append(labels, group.Options.BaseURI)
+	}
+	if e.environmentLabel {
+		labels = append(labels, group.Environment)
+	}
+	return labels
+}
+
+// collectSupergroup delivers the metrics of sg as a whole, once however many
+// groups it has, labelled like the app of its default group.
+func (e *Exporter) collectSupergroup(ch chan<- prometheus.Metric, sg SuperGroup) {
+	group, ok := defaultGroup(sg)
+	if !ok {
+		return
+	}
+	labels := e.appLabels(sg.Name, group)
+
+	ch <- prometheus.MustNewConstMetric(e.supergroupReady, prometheus.GaugeValue, boolToFloat(sg.State == "READY"), labels...)
+	ch <- prometheus.MustNewConstMetric(e.supergroupCapacity, prometheus.GaugeValue, parseFloat(sg.CapacityUsed), labels...)
+
+	byType := make(map[string]int)
+	for _, group := range sg.Groups {
+		byType[group.AppType] += len(group.Processes)
+	}
+	for appType, procs := range byType {
+		appTypeLabels := append([]string{}, labels...)
+		appTypeLabels = append(appTypeLabels, appType)
+		ch <- prometheus.MustNewConstMetric(e.supergroupByType, prometheus.GaugeValue, float64(procs), appTypeLabels...)
+	}
+}
+
+// collectApp delivers the metrics of a single app, one of the groups of sg.
+// Unless info is fresh, as opposed to cached info being replayed, the state
+// carried between scrapes is left untouched.
+func (e *Exporter) collectApp(ch chan<- prometheus.Metric, info *Info, sg SuperGroup, group Group, fresh bool) {
+	name := appName(sg, group)
+	appLabels := e.appLabels(name, group)
+
+	ready := sg.State == "READY"
+
+	ch <- prometheus.MustNewConstMetric(e.appRequestQueue, prometheus.GaugeValue, parseFloat(group.RequestQueueSize), appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appCapacityUsed, prometheus.GaugeValue, parseFloat(group.CapacityUsed), appLabels...)
+	for state, count := range map[string]string{
+		"enabled":   group.EnabledProcessCount,
+		"disabling": group.DisablingProcessCount,
+		"disabled":  group.DisabledProcessCount,
+	} {
+		stateLabels := append([]string{}, appLabels...)
+		stateLabels = append(stateLabels, state)
+		ch <- prometheus.MustNewConstMetric(e.appProcsByState, prometheus.GaugeValue, parseFloat(count), stateLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(e.appProcsSpawning, prometheus.GaugeValue, parseFloat(group.ProcessesSpawning), appLabels...)
+	queue := parseFloat(group.RequestQueueSize)
+	queueMax, ok := e.appQueueMax[name]
+	if !ok {
+		queueMax = queue
+	}
+	ch <- prometheus.MustNewConstMetric(e.appRequestQueueMax, prometheus.GaugeValue, queueMax, appLabels...)
+	if fresh && e.appQueueMaxReset {
+		delete(e.appQueueMax, name)
+	}
+	procs := len(group.Processes)
+	previous, ok := e.appProcessCounts[name]
+	if fresh {
+		e.appProcessCounts[name] = procs
+	}
+	ch <- prometheus.MustNewConstMetric(e.appProcsChanged, prometheus.GaugeValue, boolToFloat(ok && previous != procs), appLabels...)
+
+	ch <- prometheus.MustNewConstMetric(e.appCapPressure, prometheus.GaugeValue, parseFloat(group.CapacityUsed)-parseFloat(group.EnabledProcessCount), appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appHeadroom, prometheus.GaugeValue, float64(processHeadroom(info, group)), appLabels...)
+	if max := maxAppProcesses(info, group); max > 0 {
+		ch <- prometheus.MustNewConstMetric(e.appUtilization, prometheus.GaugeValue, parseFloat(group.EnabledProcessCount)/float64(max), appLabels...)
+
+		surge := len(group.Processes) - max
+		if surge < 0 {
+			surge = 0
+		}
+		ch <- prometheus.MustNewConstMetric(e.appSurgeProcs, prometheus.GaugeValue, float64(surge), appLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(e.appIdleProcs, prometheus.GaugeValue, float64(e.idleProcesses(group.Processes)), appLabels...)
+
+	startupLabels := append([]string{}, appLabels...)
+	startupLabels = append(startupLabels, group.Options.StartupFile)
+	if e.startCommandLabel {
+		startupLabels = append(startupLabels, group.Options.StartCommand)
+	}
+	ch <- prometheus.MustNewConstMetric(e.appStartupInfo, prometheus.GaugeValue, 1, startupLabels...)
+
+	if e.processTitleInfo {
+		processTitleLabels := append([]string{}, appLabels...)
+		processTitleLabels = append(processTitleLabels, group.Options.ProcessTitle)
+		ch <- prometheus.MustNewConstMetric(e.appProcessTitle, prometheus.GaugeValue, 1, processTitleLabels...)
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.appHasAPIKey, prometheus.GaugeValue, boolToFloat(group.Options.APIKey != ""), appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appDebugger, prometheus.GaugeValue, boolToFloat(group.Options.Debugger == "true"), appLabels...)
+
+	ch <- prometheus.MustNewConstMetric(e.appMaxOOBWork, prometheus.GaugeValue, parseFloat(group.Options.MaxOutOfBandWorkInstances), appLabels...)
+
+	interpreterLabels := append([]string{}, appLabels...)
+	interpreterLabels = append(interpreterLabels, group.Options.RubyBinPath, group.Options.PythonBinPath, group.Options.NodeJSBinPath)
+	ch <- prometheus.MustNewConstMetric(e.appInterpreterInfo, prometheus.GaugeValue, 1, interpreterLabels...)
+
+	if attrs := group.Options.StickySessionCookieAttrs; attrs != "" {
+		stickyCookieLabels := append([]string{}, appLabels...)
+		stickyCookieLabels = append(stickyCookieLabels, attrs)
+		ch <- prometheus.MustNewConstMetric(e.appStickyCookie, prometheus.GaugeValue, 1, stickyCookieLabels...)
+	}
+
+	restartDir := restartDirectory(group.Options)
+	restartLabels := append([]string{}, appLabels...)
+	restartLabels = append(restartLabels, restartDir)
+	ch <- prometheus.MustNewConstMetric(e.appRestartInfo, prometheus.GaugeValue, 1, restartLabels...)
+	if e.restartTxt {
+		_, err := os.Stat(filepath.Join(restartDir, "restart.txt"))
+		ch <- prometheus.MustNewConstMetric(e.appRestartTxt, prometheus.GaugeValue, boolToFloat(err == nil), appLabels...)
+	}
+
+	var busyness, busy, sessions, disabledBusy, cpu, inconsistent, swapping, swap float64
+	concurrencies := make(map[string]float64)
+	spawners := make(map[string]bool)
+	for _, proc := range group.Processes {
+		concurrencies[proc.Concurrency]++
+		spawners[proc.SpawnerCreationTime] = true
+		if proc.Enabled == "ENABLED" && proc.LifeStatus != "" && proc.LifeStatus != "ALIVE" {
+			inconsistent++
+		}
+		busyness += parseFloat(proc.Busyness)
+		cpu += parseFloat(proc.CPU)
+		if procSwap := parseFloat(proc.Swap); procSwap > 0 {
+			swapping++
+			swap += procSwap * bytesPerKilobyte
+		}
+		sessions += parseFloat(proc.Sessions)
+		if parseFloat(proc.Sessions) > 0 {
+			busy++
+		}
+		if proc.Enabled == "DISABLED" && parseFloat(proc.Sessions) > 0 {
+			disabledBusy++
+		}
+	}
+	if procs > 0 {
+		ch <- prometheus.MustNewConstMetric(e.appAvgBusyness, prometheus.GaugeValue, busyness/float64(procs), appLabels...)
+		ch <- prometheus.MustNewConstMetric(e.appBusyFraction, prometheus.GaugeValue, busy/float64(procs), appLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(e.appSessions, prometheus.GaugeValue, sessions, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appDisabledBusy, prometheus.GaugeValue, disabledBusy, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appCPU, prometheus.GaugeValue, cpu, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appSwapping, prometheus.GaugeValue, swapping, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appSwap, prometheus.GaugeValue, swap, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appInconsistent, prometheus.GaugeValue, inconsistent, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appSpawnerGens, prometheus.GaugeValue, float64(len(spawners)), appLabels...)
+	for concurrency, count := range concurrencies {
+		concurrencyLabels := append([]string{}, appLabels...)
+		concurrencyLabels = append(concurrencyLabels, concurrency)
+		ch <- prometheus.MustNewConstMetric(e.appByConcurrency, prometheus.GaugeValue, count, concurrencyLabels...)
+	}
+	ch <- prometheus.MustNewConstMetric(e.appTotalDemand, prometheus.GaugeValue, queue+sessions, appLabels...)
+	ch <- prometheus.MustNewConstMetric(e.appStuckSpawning, prometheus.GaugeValue, float64(e.stuckSpawning(group)), appLabels...)
+	if e.spawnHistogram {
+		if fresh {
+			e.observeSpawnDurations(name, appLabels, group.Processes)
+		}
+	} else if min, max, avg, ok := spawnDurations(group.Processes); ok {
+		ch <- prometheus.MustNewConstMetric(e.appSpawnMin, prometheus.GaugeValue, min, appLabels...)
+		ch <- prometheus.MustNewConstMetric(e.appSpawnMax, prometheus.GaugeValue, max, appLabels...)
+		ch <- prometheus.MustNewConstMetric(e.appSpawnAvg, prometheus.GaugeValue, avg, appLabels...)
+	}
+	if _, last, ok := spawnEndRange(group.Processes); ok {
+		ch <- prometheus.MustNewConstMetric(e.appSinceSpawn, prometheus.GaugeValue, e.now().Sub(last).Seconds(), appLabels...)
+	}
+	if first, last, ok := spawnEndRange(liveProcesses(group.Processes)); ok {
+		now := e.now()
+		ch <- prometheus.MustNewConstMetric(e.appOldestProc, prometheus.GaugeValue, now.Sub(first).Seconds(), appLabels...)
+		ch <- prometheus.MustNewConstMetric(e.appNewestProc, prometheus.GaugeValue, now.Sub(last).Seconds(), appLabels...)
+	}
+	if e.maxProcessAge > 0 {
+		ch <- prometheus.MustNewConstMetric(e.appOverMaxAge, prometheus.GaugeValue, float64(e.processesOverMaxAge(group.Processes)), appLabels...)
+	}
+
+	// Processes of apps which aren't ready yet may be partially populated.
+	if !ready || (len(e.detailedApps) > 0 && !e.detailedApps[sg.Name]) {
+		return
+	}
+	e.collectProcesses(ch, info, name, group, appLabels, fresh)
+}
+
+// collectProcesses delivers the metrics of each process of an app, sampling
+// them for the state carried between scrapes if fresh.
+func (e *Exporter) collectProcesses(ch chan<- prometheus.Metric, info *Info, name string, group Group, appLabels []string, fresh bool) {
+	maxProcesses := parseInt(info.MaxProcessCount)
+
+	var ids map[string]int
+	idLabel := strconv.Itoa
+	if e.idStrategy == idStrategyGUPIDHash {
+		ids = make(map[string]int, len(group.Processes))
+		for _, proc := range group.Processes {
+			ids[proc.PID] = int(gupidHash(proc.GUPID))
+		}
+		idLabel = func(id int) string { return fmt.Sprintf("%08x", uint32(id)) }
+	} else {
+		// Update the app's process identifiers map. Apps are bucketed
+		// separately as their PIDs don't overlap.
+		if fresh {
+			e.processIdentifiers[name] = updateProcesses(e.processIdentifiers[name], group.Processes, maxProcesses)
+		}
+		ids = e.processIdentifiers[name]
+	}
+
+	trackedLabels := []string{name}
+	if e.environmentLabel {
+		trackedLabels = append(trackedLabels, group.Environment)
+	}
+	ch <- prometheus.MustNewConstMetric(e.trackedProcesses, prometheus.GaugeValue, float64(len(ids)), trackedLabels...)
+
+	// Processes sharing a PID, such as ones yet to report it, share a
+	// bucket, so only the first of them is exported.
+	var mapped []Process
+	seen := make(map[string]bool)
+	for _, proc := range group.Processes {
+		if _, ok := ids[proc.PID]; ok && !seen[proc.PID] {
+			seen[proc.PID] = true
+			mapped = append(mapped, proc)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(e.appUnmappedProcs, prometheus.GaugeValue, float64(len(group.Processes)-len(mapped)), appLabels...)
+
+	var topMemory map[string]bool
+	if e.topMemoryProcesses > 0 {
+		var other float64
+		topMemory, other = largestMemory(mapped, e.topMemoryProcesses)
+		ch <- prometheus.MustNewConstMetric(e.appOtherMemory, prometheus.GaugeValue, other, appLabels...)
+	}
+
+	for _, proc := range mapped {
+		if bucketID, ok := ids[proc.PID]; ok {
+			bucketLabels := []string{name, idLabel(bucketID)}
+			if e.environmentLabel {
+				bucketLabels = append(bucketLabels, group.Environment)
+			}
+
+			labels := append([]string{}, bucketLabels...)
+			if e.gupidLabel {
+				labels = append(labels, proc.GUPID)
+			}
+
+			exportMemory := topMemory == nil || topMemory[proc.PID]
+			if exportMemory {
+				ch <- prometheus.MustNewConstMetric(e.procMemory, prometheus.GaugeValue, parseFloat(proc.RealMemory), labels...)
+				ch <- prometheus.MustNewConstMetric(e.procPSS, prometheus.GaugeValue, parseFloat(proc.PSS), labels...)
+				ch <- prometheus.MustNewConstMetric(e.procSwap, prometheus.GaugeValue, parseFloat(proc.Swap), labels...)
+				ch <- prometheus.MustNewConstMetric(e.procPrivateDirty, prometheus.GaugeValue, parseFloat(proc.PrivateDirty), labels...)
+				ch <- prometheus.MustNewConstMetric(e.procVMSize, prometheus.GaugeValue, parseFloat(proc.VMSize), labels...)
+			}
+			ch <- prometheus.MustNewConstMetric(e.requestsProcessed, e.requestsProcessedType, parseFloat(proc.RequestsProcessed), labels...)
+			ch <- prometheus.MustNewConstMetric(e.procCPU, prometheus.GaugeValue, parseFloat(proc.CPU), labels...)
+			ch <- prometheus.MustNewConstMetric(e.procSessions, prometheus.GaugeValue, parseFloat(proc.Sessions), labels...)
+			ch <- prometheus.MustNewConstMetric(e.procConcurrency, prometheus.GaugeValue, parseFloat(proc.Concurrency), labels...)
+			ch <- prometheus.MustNewConstMetric(e.procBusyness, prometheus.GaugeValue, parseFloat(proc.Busyness), labels...)
+
+			if vmsize := parseFloat(proc.VMSize); vmsize > 0 && exportMemory {
+				ch <- prometheus.MustNewConstMetric(e.procMemEfficiency, prometheus.GaugeValue, parseFloat(proc.RealMemory)/vmsize, labels...)
+			}
+
+			if startTime, err := strconv.ParseInt(proc.SpawnStartTime, 10, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(e.procStartTime, prometheus.GaugeValue, float64(startTime)/microsecondsPerSecond, labels...)
+			}
+
+			b := bucket{name: name, id: bucketID}
+			if fresh {
+				e.sampleBucket(b, proc, info.fetched)
+			}
+			ch <- prometheus.MustNewConstMetric(e.requestRate, prometheus.GaugeValue, e.bucketRate[b], labels...)
+			if exportMemory {
+				ch <- prometheus.MustNewConstMetric(e.memoryGrowth, prometheus.GaugeValue, e.bucketGrowth[b], labels...)
+			}
+
+			ch <- prometheus.MustNewConstMetric(e.procRestarts, prometheus.CounterValue, e.bucketRestarts[b], bucketLabels...)
+			if e.firstSeenMetric {
+				firstSeen := float64(e.bucketFirstSeen[b].UnixNano()) / nanosecondsPerSecond
+				ch <- prometheus.MustNewConstMetric(e.procFirstSeen, prometheus.GaugeValue, firstSeen, labels...)
+			}
+		}
+	}
+}
+
+// pruneBuckets forgets the buckets of processes no longer in info, so that
+// the state kept for them doesn't grow as processes are replaced. Under the
+// bucket strategy, which reuses ids, only the buckets of apps which are gone
+// are forgotten.
+func (e *Exporter) pruneBuckets(info *Info) {
+	apps := make(map[string]bool)
+	live := make(map[bucket]bool)
+	gupids := make(map[string]bool)
+	for _, sg := range info.SuperGroups {
+		for _, group := range sg.Groups {
+			name := appName(sg, group)
+			apps[name] = true
+			for _, proc := range group.Processes {
+				live[bucket{name: name, id: int(gupidHash(proc.GUPID))}] = true
+				gupids[proc.GUPID] = true
+			}
+		}
+	}
+
+	for b := range e.bucketPIDs {
+		if !apps[b.name] || (e.idStrategy == idStrategyGUPIDHash && !live[b]) {
+			delete(e.bucketPIDs, b)
+			delete(e.bucketRestarts, b)
+			delete(e.bucketFirstSeen, b)
+			delete(e.bucketRequests, b)
+			delete(e.bucketMemory, b)
+			delete(e.bucketSampled, b)
+			delete(e.bucketRate, b)
+			delete(e.bucketGrowth, b)
+		}
+	}
+	for name := range e.processIdentifiers {
+		if !apps[name] {
+			delete(e.processIdentifiers, name)
+		}
+	}
+	for name := range e.appProcessCounts {
+		if !apps[name] {
+			delete(e.appProcessCounts, name)
+		}
+	}
+	for name := range e.appQueueMax {
+		if !apps[name] {
+			delete(e.appQueueMax, name)
+		}
+	}
+	for gupid := range e.spawnObserved {
+		if !gupids[gupid] {
+			delete(e.spawnObserved, gupid)
+		}
+	}
+	for name, labels := range e.spawnLabels {
+		if !apps[name] {
+			e.appSpawnDuration.DeleteLabelValues(labels...)
+			delete(e.spawnLabels, name)
+		}
+	}
+}
+
+// observeSpawnDurations observes the spawn durations of those of an app's
+// processes which finished spawning since the previous observation.
+func (e *Exporter) observeSpawnDurations(name string, appLabels []string, processes []Process) {
+	e.spawnLabels[name] = appLabels
+	for _, proc := range processes {
+		if e.spawnObserved[proc.GUPID] {
+			continue
+		}
+		if seconds, ok := spawnDuration(proc); ok {
+			e.appSpawnDuration.WithLabelValues(appLabels...).Observe(seconds)
+			e.spawnObserved[proc.GUPID] = true
+		}
+	}
+}
+
+// sampleBucket records proc as the process in b at now, counting a restart
+// if it replaced another, and updates its rates of change.
+func (e *Exporter) sampleBucket(b bucket, proc Process, now time.Time) {
+	pid, seen := e.bucketPIDs[b]
+	replaced := seen && pid != proc.PID
+	if replaced {
+		e.bucketRestarts[b]++
+	}
+	if !seen || replaced {
+		e.bucketFirstSeen[b] = now
+	}
+	e.bucketPIDs[b] = proc.PID
+
+	// A new process in the bucket has no previous count to compare with.
+	requests := parseFloat(proc.RequestsProcessed)
+	memory := parseFloat(proc.RealMemory) * bytesPerKilobyte
+	var rate, growth float64
+	if elapsed := now.Sub(e.bucketSampled[b]).Seconds(); seen && !replaced && elapsed > 0 {
+		rate = (requests - e.bucketRequests[b]) / elapsed
+		growth = (memory - e.bucketMemory[b]) / elapsed
+	}
+	e.bucketRequests[b], e.bucketMemory[b], e.bucketSampled[b] = requests, memory, now
+	e.bucketRate[b], e.bucketGrowth[b] = rate, growth
+}
+
+// statusWithDeadline returns passenger's status, giving up once the collect
+// timeout passes so that /metrics always responds promptly. The abandoned
+// status call is only stopped once ctx is done.
+func (e *Exporter) statusWithDeadline(ctx context.Context) (*Info, error) {
+	if e.collectTimeout <= 0 {
+		return e.status(ctx)
+	}
+
+	type result struct {
+		info *Info
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		info, err := e.status(ctx)
+		done <- result{info, err}
+	}()
+
+	select {
+	case <-time.After(e.collectTimeout):
+		e.collectTimeouts++
+		return nil, fmt.Errorf("collect timed out after %f seconds", e.collectTimeout.Seconds())
+	case r := <-done:
+		return r.info, r.err
+	}
+}
+
+// latestStatus returns passenger's status for a scrape, either fetching it or
+// taking the one last polled.
+func (e *Exporter) latestStatus(ctx context.Context) (*Info, error) {
+	if !e.polling {
+		info, err := e.statusWithDeadline(ctx)
+		if err == nil {
+			e.observeQueues(info)
+		}
+		return info, err
+	}
+
+	e.pollMutex.Lock()
+	defer e.pollMutex.Unlock()
+	return e.polledInfo, e.polledErr
+}
+
+// startPolling fetches passenger's status once and then every interval in
+// the background, so that scrapes no longer wait for passenger. It must be
+// called before the exporter is collected.
+func (e *Exporter) startPolling(interval time.Duration) {
+	e.polling = true
+	e.pollStatus()
+
+	go func() {
+		for range time.Tick(interval) {
+			e.pollStatus()
+		}
+	}()
+}
+
+func (e *Exporter) pollStatus() {
+	info, err := e.status(context.Background())
+
+	// Queues are observed on every poll, catching those which clear before
+	// the next scrape.
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err == nil {
+		e.observeQueues(info)
+	}
+
+	e.pollMutex.Lock()
+	defer e.pollMutex.Unlock()
+	e.polledInfo, e.polledErr = info, err
+}
+
+// observeQueues raises the highest request queue size seen of each app to
+// its size in info, which must be called for every status fetched.
+func (e *Exporter) observeQueues(info *Info) {
+	for _, sg := range info.SuperGroups {
+		for _, group := range sg.Groups {
+			name := appName(sg, group)
+			queue := parseFloat(group.RequestQueueSize)
+			if max, ok := e.appQueueMax[name]; !ok || queue > max {
+				e.appQueueMax[name] = queue
+			}
+		}
+	}
 }
 
-// NewExporter returns an initialized exporter.
-func NewExporter(cmd string, timeout float64) *Exporter {
-	cmdComponents := strings.Split(cmd, " ")
+// debugStatusHandler serves passenger's status as parsed by the exporter, as
+// indented JSON with API keys redacted.
+func (e *Exporter) debugStatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := e.debugStatus(r.Context())
+		serveStatus(w, info, err)
+	})
+}
 
-	return &Exporter{
-		cmd:     cmdComponents[0],
-		args:    cmdComponents[1:],
-		timeout: time.Duration(timeout * nanosecondsPerSecond),
-		up: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "up"),
-			"Current health of passenger.",
-			nil,
-			nil,
-		),
-		version: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "version"),
-			"Version of passenger.",
-			[]string{"version"},
-			nil,
-		),
-		topLevelRequestQueue: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "top_level_request_queue"),
-			"Number of requests in the top-level queue.",
-			nil,
-			nil,
-		),
-		maxProcessCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "max_processes"),
-			"Configured maximum number of processes.",
-			nil,
-			nil,
-		),
-		currentProcessCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "current_processes"),
-			"Current number of processes.",
-			nil,
-			nil,
-		),
-		appCount: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "app_count"),
-			"Number of apps.",
-			nil,
-			nil,
-		),
-		appRequestQueue: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "app_request_queue"),
-			"Number of requests in the app queue.",
-			[]string{"name"},
-			nil,
-		),
-		appProcsSpawning: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "app_procs_spawning"),
-			"Number of processes spawning.",
-			[]string{"name"},
-			nil,
-		),
-		requestsProcessed: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "requests_processed_total"),
-			"Number of requests served by a process.",
-			[]string{"name", "id"},
-			nil,
-		),
-		procStartTime: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "proc_start_time_seconds"),
-			"Number of seconds since process started.",
-			[]string{"name", "id"},
-			nil,
-		),
-		procMemory: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "proc_memory"),
-			"Memory consumed by a process",
-			[]string{"name", "id"},
-			nil,
-		),
-	}
-}
-
-// Describe describes all the metrics exported by the passenger exporter.
-func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	ch <- e.up
-	ch <- e.version
-	ch <- e.topLevelRequestQueue
-	ch <- e.maxProcessCount
-	ch <- e.currentProcessCount
-	ch <- e.appCount
-	ch <- e.appRequestQueue
-	ch <- e.appProcsSpawning
-	ch <- e.requestsProcessed
-	ch <- e.procStartTime
-	ch <- e.procMemory
+// debugStatus returns passenger's status for the debug status endpoint. Like
+// scrapes, it is subject to the collect timeout, takes the status last polled
+// and reuses any status fetched within the minimum scrape interval.
+func (e *Exporter) debugStatus(ctx context.Context) (*Info, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.minScrapeInterval > 0 {
+		for _, info := range []*Info{e.lastInfo, e.debugInfo} {
+			if info != nil && e.now().Sub(info.fetched) < e.minScrapeInterval {
+				return info, nil
+			}
+		}
+	}
+
+	info, err := e.latestStatus(ctx)
+	if err == nil {
+		e.debugInfo = info
+	}
+	return info, err
 }
 
-// Collect fetches the statistics from passenger, and delivers them as
-// Prometheus metrics.
-func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	info, err := e.status()
+// serveStatus writes info as indented JSON with API keys redacted, or err if
+// it could not be fetched.
+func serveStatus(w http.ResponseWriter, info *Info, err error) {
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
-		log.Errorf("failed to collect status from passenger: %s", err)
+		http.Error(w, fmt.Sprintf("failed to collect status from passenger: %s", err), http.StatusInternalServerError)
 		return
 	}
-	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
-	ch <- prometheus.MustNewConstMetric(e.version, prometheus.GaugeValue, 1, info.PassengerVersion)
 
-	ch <- prometheus.MustNewConstMetric(e.topLevelRequestQueue, prometheus.GaugeValue, parseFloat(info.TopLevelRequestQueueSize))
-	ch <- prometheus.MustNewConstMetric(e.maxProcessCount, prometheus.GaugeValue, parseFloat(info.MaxProcessCount))
-	ch <- prometheus.MustNewConstMetric(e.currentProcessCount, prometheus.GaugeValue, parseFloat(info.CurrentProcessCount))
-	ch <- prometheus.MustNewConstMetric(e.appCount, prometheus.GaugeValue, parseFloat(info.AppCount))
+	content, err := json.MarshalIndent(redactStatus(info), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
 
-	for _, sg := range info.SuperGroups {
-		ch <- prometheus.MustNewConstMetric(e.appRequestQueue, prometheus.GaugeValue, parseFloat(sg.Group.RequestQueueSize), sg.Name)
-		ch <- prometheus.MustNewConstMetric(e.appProcsSpawning, prometheus.GaugeValue, parseFloat(sg.Group.ProcessesSpawning), sg.Name)
-
-		// Update process identifiers map.
-		processIdentifiers = updateProcesses(processIdentifiers, sg.Group.Processes, parseInt(info.MaxProcessCount))
-		for _, proc := range sg.Group.Processes {
-			if bucketID, ok := processIdentifiers[proc.PID]; ok {
-				ch <- prometheus.MustNewConstMetric(e.procMemory, prometheus.GaugeValue, parseFloat(proc.RealMemory), sg.Name, strconv.Itoa(bucketID))
-				ch <- prometheus.MustNewConstMetric(e.requestsProcessed, prometheus.CounterValue, parseFloat(proc.RequestsProcessed), sg.Name, strconv.Itoa(bucketID))
-
-				if startTime, err := strconv.Atoi(proc.SpawnStartTime); err == nil {
-					ch <- prometheus.MustNewConstMetric(e.procStartTime, prometheus.GaugeValue, float64(startTime/nanosecondsPerSecond),
-						sg.Name, strconv.Itoa(bucketID),
-					)
+// redactStatus returns a copy of info without secrets.
+func redactStatus(info *Info) *Info {
+	redacted := *info
+	redacted.SuperGroups = make([]SuperGroup, len(info.SuperGroups))
+	for i, sg := range info.SuperGroups {
+		sg.Groups = append([]Group{}, sg.Groups...)
+		for j := range sg.Groups {
+			options := &sg.Groups[j].Options
+			for _, secret := range []*string{&options.APIKey, &options.USTRouterUsername, &options.USTRouterPassword} {
+				if *secret != "" {
+					*secret = "<redacted>"
 				}
 			}
 		}
+		redacted.SuperGroups[i] = sg
+	}
+	return &redacted
+}
+
+// prime runs a collection whose metrics are discarded, populating the
+// process identifiers and other state carried between scrapes.
+func prime(c prometheus.Collector) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	for range ch {
+	}
+}
+
+// status fetches and parses passenger's status. Output which was cut short,
+// as happens while an instance restarts, is fetched once more after a short
+// jittered delay, whereas any other parse error fails immediately.
+func (e *Exporter) status(ctx context.Context) (*Info, error) {
+	info, err := e.fetchStatus(ctx)
+	if perr, ok := err.(parseError); !ok || !truncated(perr.err) {
+		return info, err
+	}
+
+	delay := e.truncatedRetryDelay/2 + time.Duration(rand.Int63n(int64(e.truncatedRetryDelay)+1))
+	log.Warnf("passenger's status was truncated, retrying in %s: %s", delay, err)
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return nil, err
+	}
+	return e.fetchStatus(ctx)
+}
+
+func (e *Exporter) fetchStatus(ctx context.Context) (*Info, error) {
+	r, err := e.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parseOutput(bytes.NewReader(out))
+	if err != nil {
+		return nil, parseError{err}
+	}
+	info.outputBytes = len(out)
+	info.fetched = e.now()
+	return info, nil
+}
+
+// parseError is returned by status when passenger's output could not be
+// parsed, as opposed to not being fetched at all.
+type parseError struct {
+	err error
+}
+
+func (e parseError) Error() string {
+	return fmt.Sprintf("failed to parse status: %s", e.err)
+}
+
+// truncated reports whether the parse error err was caused by the output
+// ending early, rather than by it not matching the expected schema.
+func truncated(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
 	}
+	syntaxErr, ok := err.(*xml.SyntaxError)
+	return ok && syntaxErr.Msg == "unexpected EOF"
 }
 
-func (e *Exporter) status() (*Info, error) {
+// command runs the passenger command and returns its output. The command is
+// killed if ctx is done before it exits.
+func (e *Exporter) command(ctx context.Context) (io.Reader, error) {
 	var (
-		out bytes.Buffer
-		cmd = exec.Command(e.cmd, e.args...)
+		out    bytes.Buffer
+		stderr bytes.Buffer
+		cmd    = exec.CommandContext(ctx, e.cmd, e.args...)
 	)
 	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	cmd.Dir = e.dir
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
 
 	err := cmd.Start()
 	if err != nil {
@@ -309,6 +2230,11 @@ func (e *Exporter) status() (*Info, error) {
 		done <- cmd.Wait()
 	}()
 
+	start := e.now()
+	defer func() {
+		atomic.StoreInt64(&e.commandWait, int64(e.now().Sub(start)))
+	}()
+
 	select {
 	case <-time.After(e.timeout):
 		if err := cmd.Process.Kill(); err != nil {
@@ -317,12 +2243,19 @@ func (e *Exporter) status() (*Info, error) {
 		err = fmt.Errorf("status command timed out after %f seconds", e.timeout.Seconds())
 		return nil, err
 	case err := <-done:
+		atomic.StoreInt64(&e.commandStderr, int64(stderr.Len()))
+		if stderr.Len() > 0 {
+			log.Warnf("status command wrote to stderr: %s", strings.TrimSpace(stderr.String()))
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("status command cancelled: %s", ctx.Err())
+		}
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return parseOutput(&out)
+	return &out, nil
 }
 
 func parseOutput(r io.Reader) (*Info, error) {
@@ -345,6 +2278,13 @@ func parseFloat(val string) float64 {
 	return v
 }
 
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func parseInt(val string) int {
 	v, err := strconv.Atoi(val)
 	if err != nil {
@@ -354,6 +2294,278 @@ func parseInt(val string) int {
 	return v
 }
 
+// stuckSpawning counts processes of group which started spawning longer ago
+// than the app's start timeout but never finished.
+func (e *Exporter) stuckSpawning(group Group) int {
+	// start_timeout is in milliseconds, spawn times in microseconds.
+	startTimeout := time.Duration(parseInt(group.Options.StartTimeout)) * time.Millisecond
+	now := e.now()
+
+	var stuck int
+	for _, proc := range group.Processes {
+		start, err := strconv.ParseInt(proc.SpawnStartTime, 10, 64)
+		if err != nil || start <= 0 || proc.SpawnEndTime != "0" {
+			continue
+		}
+		if now.Sub(time.Unix(0, start*int64(time.Microsecond))) > startTimeout {
+			stuck++
+		}
+	}
+	return stuck
+}
+
+// oldestSpawnTime returns the earliest time any process, or the spawner it
+// was forked from, was created, as a proxy for when the instance started.
+func oldestSpawnTime(info *Info) (time.Time, bool) {
+	var oldest int64
+	for _, sg := range info.SuperGroups {
+		for _, group := range sg.Groups {
+			for _, proc := range group.Processes {
+				for _, val := range []string{proc.SpawnerCreationTime, proc.SpawnEndTime} {
+					// Spawn times are in microseconds.
+					t, err := strconv.ParseInt(val, 10, 64)
+					if err == nil && t > 0 && (oldest == 0 || t < oldest) {
+						oldest = t
+					}
+				}
+			}
+		}
+	}
+	if oldest == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, oldest*int64(time.Microsecond)), true
+}
+
+// largestMemory returns the PIDs of the n processes using the most memory,
+// and the memory used by the others in total.
+func largestMemory(processes []Process, n int) (map[string]bool, float64) {
+	sorted := append([]Process{}, processes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return parseFloat(sorted[i].RealMemory) > parseFloat(sorted[j].RealMemory)
+	})
+
+	top := make(map[string]bool)
+	var other float64
+	for i, proc := range sorted {
+		if i < n {
+			top[proc.PID] = true
+		} else {
+			other += parseFloat(proc.RealMemory)
+		}
+	}
+	return top, other
+}
+
+// liveProcesses returns those of processes which are alive, as opposed to
+// e.g. shutting down after being replaced.
+func liveProcesses(processes []Process) []Process {
+	var live []Process
+	for _, proc := range processes {
+		if proc.LifeStatus == "" || proc.LifeStatus == "ALIVE" {
+			live = append(live, proc)
+		}
+	}
+	return live
+}
+
+// spawnEndRange returns when the first and the most recently spawned of
+// processes finished spawning, if any has.
+func spawnEndRange(processes []Process) (first, last time.Time, ok bool) {
+	var min, max int64
+	for _, proc := range processes {
+		// Spawn times are in microseconds.
+		t, err := strconv.ParseInt(proc.SpawnEndTime, 10, 64)
+		if err != nil || t <= 0 {
+			continue
+		}
+		if min == 0 || t < min {
+			min = t
+		}
+		if t > max {
+			max = t
+		}
+	}
+	if max == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(0, min*int64(time.Microsecond)), time.Unix(0, max*int64(time.Microsecond)), true
+}
+
+// gupidHash returns a short stable hash of passenger's globally unique
+// process id.
+func gupidHash(gupid string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(gupid))
+	return h.Sum32()
+}
+
+// restartDirectory returns the directory in which passenger watches for an
+// app's restart.txt, defaulting to the tmp directory of the app's root.
+func restartDirectory(options Options) string {
+	if options.RestartDirectory != "" {
+		return options.RestartDirectory
+	}
+	return filepath.Join(options.AppRoot, "tmp")
+}
+
+// spawnDurations returns the shortest, longest and average spawn time in
+// seconds of those processes which finished spawning, if any has.
+func spawnDurations(processes []Process) (min, max, avg float64, ok bool) {
+	seconds := spawnSeconds(processes)
+	for i, s := range seconds {
+		if i == 0 || s < min {
+			min = s
+		}
+		if i == 0 || s > max {
+			max = s
+		}
+		avg += s
+	}
+	if len(seconds) > 0 {
+		avg /= float64(len(seconds))
+	}
+	return min, max, avg, len(seconds) > 0
+}
+
+// spawnDurationBuckets are the upper bounds in seconds of the buckets of the
+// spawn duration histogram.
+var spawnDurationBuckets = []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+
+// spawnSeconds returns the spawn durations in seconds of those processes
+// which finished spawning.
+func spawnSeconds(processes []Process) []float64 {
+	var durations []float64
+	for _, proc := range processes {
+		if seconds, ok := spawnDuration(proc); ok {
+			durations = append(durations, seconds)
+		}
+	}
+	return durations
+}
+
+// spawnDuration returns the spawn duration in seconds of proc, if it
+// finished spawning.
+func spawnDuration(proc Process) (float64, bool) {
+	// Spawn times are in microseconds.
+	start, err := strconv.ParseInt(proc.SpawnStartTime, 10, 64)
+	if err != nil || start <= 0 {
+		return 0, false
+	}
+	end, err := strconv.ParseInt(proc.SpawnEndTime, 10, 64)
+	if err != nil || end < start {
+		return 0, false
+	}
+	return (time.Duration(end-start) * time.Microsecond).Seconds(), true
+}
+
+// anomalousTimestamps counts processes whose timestamps are implausible: last
+// used before they finished spawning, or in the future.
+func (e *Exporter) anomalousTimestamps(processes []Process) int {
+	// Spawn and usage times are in microseconds.
+	now := e.now().UnixNano() / int64(time.Microsecond)
+
+	var anomalies int
+	for _, proc := range processes {
+		// Unparseable timestamps are taken to be unset.
+		start, _ := strconv.ParseInt(proc.SpawnStartTime, 10, 64)
+		end, _ := strconv.ParseInt(proc.SpawnEndTime, 10, 64)
+		lastUsed, _ := strconv.ParseInt(proc.LastUsed, 10, 64)
+		if start > now || end > now || lastUsed > now || (end > 0 && lastUsed > 0 && lastUsed < end) {
+			anomalies++
+		}
+	}
+	return anomalies
+}
+
+// processesOverMaxAge counts processes which finished spawning longer ago
+// than the maximum process age.
+func (e *Exporter) processesOverMaxAge(processes []Process) int {
+	now := e.now()
+
+	var old int
+	for _, proc := range processes {
+		// Spawn times are in microseconds.
+		end, err := strconv.ParseInt(proc.SpawnEndTime, 10, 64)
+		if err != nil || end <= 0 {
+			continue
+		}
+		if now.Sub(time.Unix(0, end*int64(time.Microsecond))) > e.maxProcessAge {
+			old++
+		}
+	}
+	return old
+}
+
+// idleProcesses counts processes which have not served a request despite
+// being up for at least the configured minimum uptime.
+func (e *Exporter) idleProcesses(processes []Process) int {
+	var idle int
+	for _, proc := range processes {
+		if proc.RequestsProcessed != "0" {
+			continue
+		}
+		uptime, err := parseUptime(proc.Uptime)
+		if err != nil {
+			log.Errorf("failed to parse uptime %q: %v", proc.Uptime, err)
+			continue
+		}
+		if uptime >= e.idleMinUptime {
+			idle++
+		}
+	}
+	return idle
+}
+
+// parseUptime parses passenger's human readable process uptime, e.g.
+// "1d 2h 34m 54s".
+func parseUptime(val string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'h': time.Hour,
+		'm': time.Minute,
+		's': time.Second,
+	}
+
+	var uptime time.Duration
+	for _, field := range strings.Fields(val) {
+		unit, ok := units[field[len(field)-1]]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit in %q", field)
+		}
+		n, err := strconv.Atoi(field[:len(field)-1])
+		if err != nil {
+			return 0, err
+		}
+		uptime += time.Duration(n) * unit
+	}
+	return uptime, nil
+}
+
+// maxAppProcesses returns the maximum number of processes of group. An app
+// max_processes of 0 means the app is only limited by passenger's pool size.
+func maxAppProcesses(info *Info, group Group) int {
+	if max := parseInt(group.Options.MaxProcesses); max > 0 {
+		return max
+	}
+	return parseInt(info.MaxProcessCount)
+}
+
+// processHeadroom returns how many more processes group can spawn. An app
+// max_processes of 0 means the app is only limited by passenger's pool size.
+func processHeadroom(info *Info, group Group) int {
+	headroom := parseInt(info.MaxProcessCount) - parseInt(info.CurrentProcessCount)
+	if max := parseInt(group.Options.MaxProcesses); max > 0 {
+		if appHeadroom := max - len(group.Processes); appHeadroom < headroom {
+			headroom = appHeadroom
+		}
+	}
+	if headroom < 0 {
+		headroom = 0
+	}
+	return headroom
+}
+
 // updateProcesses updates the global map from process id:exporter id. Process
 // TTLs cause new processes to be created on a user-defined cycle. When a new
 // process replaces an old process, the new process's statistics will be
@@ -366,6 +2578,12 @@ func parseInt(val string) int {
 // within the global map storing process identifiers, or mapped to
 // pid:id pair in the map.
 func updateProcesses(old map[string]int, processes []Process, maxProcesses int) map[string]int {
+	// An unconfigured or unparseable max would otherwise leave no room for
+	// the known ids.
+	if maxProcesses <= 0 {
+		maxProcesses = len(processes)
+	}
+
 	var (
 		updated = make(map[string]int)
 		found   = make([]string, maxProcesses)
@@ -373,7 +2591,7 @@ func updateProcesses(old map[string]int, processes []Process, maxProcesses int)
 	)
 
 	for _, p := range processes {
-		if id, ok := old[p.PID]; ok {
+		if id, ok := old[p.PID]; ok && id < len(found) {
 			found[id] = p.PID
 			// id also serves as an index.
 			// By putting the pid at a certain index, we can loop
@@ -416,36 +2634,344 @@ func updateProcesses(old map[string]int, processes []Process, maxProcesses int)
 	return updated
 }
 
+// envFlag is a repeatable flag collecting key=value environment variables.
+type envFlag []string
+
+func (f *envFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *envFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// argFlag is a repeatable flag collecting command arguments.
+type argFlag []string
+
+func (f *argFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *argFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// newPIDFileCollector returns a process collector for the PID read from path
+// on every scrape. A missing or unparsable pidfile only omits the process
+// metrics; the rest of the scrape is unaffected.
+func newPIDFileCollector(path string) prometheus.Collector {
+	return prometheus.NewProcessCollectorPIDFn(
+		func() (int, error) {
+			pid, err := readPIDFile(path)
+			if err != nil {
+				log.Debugf("skipping passenger process metrics: %s", err)
+			}
+			return pid, err
+		},
+		namespace,
+	)
+}
+
+func readPIDFile(path string) (int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading pidfile %q: %s", path, err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, fmt.Errorf("error parsing pidfile %q: %s", path, err)
+	}
+	return value, nil
+}
+
+// checkCommand returns an error if the command binary bin, run in dir,
+// cannot be found.
+func checkCommand(bin, dir string) error {
+	path := bin
+	if dir != "" && strings.Contains(bin, "/") && !filepath.IsAbs(bin) {
+		path = filepath.Join(dir, bin)
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("passenger command %q not found: %s", bin, err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, ignoring empty items.
+func splitList(val string) []string {
+	var items []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envName returns the environment variable consulted for a flag, e.g.
+// PASSENGER_COMMAND for passenger.command.
+func envName(flagName string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(flagName))
+}
+
+// flagsFromEnv sets each of the named flags from its environment variable,
+// unless the flag was given explicitly on the command line.
+func flagsFromEnv(names ...string) error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, name := range names {
+		if set[name] {
+			continue
+		}
+		value, ok := os.LookupEnv(envName(name))
+		if !ok {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %s", value, envName(name), err)
+		}
+	}
+	return nil
+}
+
 func main() {
 	var (
+		cmdEnv  envFlag
+		cmdArgs argFlag
+
 		cmd           = flag.String("passenger.command", "passenger-status --show=xml", "Passenger command for querying passenger status.")
+		cmdDir        = flag.String("passenger.command.workdir", "", "Working directory for passenger.command. Defaults to the exporter's working directory.")
 		timeout       = flag.Float64("passenger.command.timeout-seconds", 0.5, "Timeout in seconds for passenger.command.")
+		statusURL     = flag.String("passenger.status-url", "", "URL to fetch passenger's XML status from instead of running passenger.command, e.g. a mock server for integration tests.")
+		urlTimeout    = flag.Float64("passenger.status-url.timeout-seconds", 0, "Timeout in seconds for fetching passenger.status-url. Defaults to passenger.command.timeout-seconds.")
+		allowMissing  = flag.Bool("passenger.command.allow-missing", false, "Only warn at startup, rather than exit, when the binary of passenger.command cannot be found.")
+		queueMaxReset = flag.Bool("passenger.app-request-queue-max.reset", false, "Reset passenger_app_request_queue_max after every scrape instead of keeping the highest value seen since startup.")
+		idleUptime    = flag.Float64("passenger.idle-process.min-uptime-seconds", 300, "Minimum uptime in seconds before a process that has not served a request counts towards passenger_app_idle_processes.")
+		hostLabel     = flag.Bool("passenger.add-hostname-label", false, "Add the exporter's hostname as a host label on every passenger metric.")
+		envLabel      = flag.Bool("passenger.environment-label", false, "Add the app's environment as an environment label on app and process metrics.")
+		baseURILabel  = flag.Bool("passenger.app.base-uri-label", false, "Add the app's base URI as a base_uri label on app metrics.")
+		processTitle  = flag.Bool("passenger.app.process-title-info", false, "Export passenger_app_process_title with the title of each app's processes.")
+		detailedApps  = flag.String("passenger.detailed-apps", "", "Comma-separated names of apps to export process metrics for. Defaults to all apps.")
+		spawnHist     = flag.Bool("passenger.app.spawn-duration-histogram", false, "Export passenger_app_spawn_duration_seconds as a histogram of every process's spawn duration rather than as gauges of the minimum, maximum and average.")
+		restartTxt    = flag.Bool("passenger.app.restart-txt", false, "Export passenger_app_restart_txt_present, requiring access to each app's restart directory.")
+		startCommand  = flag.Bool("passenger.app-startup-info.start-command", false, "Add the app's start command as a start_command label on passenger_app_startup_info.")
+		discover      = flag.Bool("passenger.discover-instances", false, "Query every passenger instance found in passenger.instance-registry-dir, labelling metrics with the instance name as passenger_instance.")
+		registryDir   = flag.String("passenger.instance-registry-dir", defaultRegistryDir(), "Directory in which passenger registers its instances.")
+		metricFilter  = flag.String("passenger.enabled-metrics", "", "Comma-separated names of metrics to export. Defaults to all metrics.")
+		subsystems    = flag.Bool("passenger.metric-subsystems", false, "Name app metrics passenger_app_* and process metrics passenger_process_* instead of the legacy names. Cannot be combined with passenger.pid-file.")
+		topMemory     = flag.Int("passenger.top-memory-processes", 0, "Export memory metrics for only this many processes of each app using the most memory, summing up the others in passenger_app_other_processes_memory. 0 exports them for all processes.")
+		maxProcessAge = flag.Float64("passenger.max-process-age-seconds", 0, "Export passenger_app_processes_over_max_age, counting processes up for longer than this many seconds. 0 disables the metric.")
+		minScrape     = flag.Float64("passenger.min-scrape-interval-seconds", 0, "Minimum interval in seconds between queries of passenger. Scrapes arriving sooner are served the previous scrape's metrics.")
+		pollInterval  = flag.Float64("passenger.poll-interval-seconds", 0, "Interval in seconds at which to fetch passenger's status in the background, serving scrapes the latest one. 0 fetches it on every scrape. Cannot be combined with passenger.discover-instances.")
+		primeOnStart  = flag.Bool("passenger.prime-on-start", false, "Collect passenger's status once at startup so process ids are stable from the first scrape.")
+		untypedReqs   = flag.Bool("passenger.requests-processed-untyped", false, "Export passenger_requests_processed_total as untyped rather than as a counter, for scrapers mishandling its resets when processes are replaced.")
+		firstSeen     = flag.Bool("passenger.process.first-seen-metric", false, "Export passenger_proc_first_seen_timestamp_seconds with the time each bucket's process was first seen.")
+		idStateFile   = flag.String("passenger.id-state-file", "", "Path to a file the ids of process metrics are saved to on shutdown and restored from on startup, so they survive exporter restarts. Cannot be combined with passenger.discover-instances.")
+		idStrategy    = flag.String("passenger.id-strategy", idStrategyBucket, "How to derive the id label of process metrics: bucket reuses the ids of replaced processes, gupid-hash hashes passenger's globally unique process id.")
+		gupidLabel    = flag.Bool("passenger.process.gupid-label", false, "Add passenger's globally unique process id as a gupid label on process metrics.")
+		upIgnoreParse = flag.Bool("passenger.up-ignores-parse-errors", false, "Keep passenger_up at 1 when passenger's status cannot be parsed, exporting passenger_parse_success instead.")
+		selftestOnly  = flag.Bool("selftest", false, "Check that the exporter parses a copy of passenger's status bundled into the binary, then exit with status 0 if it does and 1 otherwise.")
+		persistFailed = flag.Bool("passenger.persist-on-failure", false, "Export the metrics of the last status fetched alongside passenger_up when fetching passenger's status fails, keeping series continuous.")
+		configFile    = flag.String("config.file", "", "Path to a configuration file of settings reapplied on SIGHUP.")
+		helpOverrides = flag.String("help-overrides.file", "", "Path to a YAML file mapping metric names to help text replacing their built-in help.")
+		pushURL       = flag.String("push.gateway-url", "", "URL of a Pushgateway to push a heartbeat to independently of scrapes, for detecting a hung exporter. Disabled by default.")
+		pushInterval  = flag.Float64("push.interval-seconds", 15, "Interval in seconds at which to push the heartbeat to push.gateway-url.")
 		pidFile       = flag.String("passenger.pid-file", "", "Optional path to a file containing the passenger PID for additional metrics.")
+		debugStatus   = flag.Bool("web.debug-status", false, "Serve passenger's status as parsed by the exporter as JSON under /debug/status, with API keys redacted. Takes the name of the instance as the instance parameter with passenger.discover-instances.")
 		metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+		protobuf      = flag.Bool("web.prefer-protobuf", false, "Serve the protobuf exposition format to clients accepting it, or not stating a preference.")
+		listenRetries = flag.Int("web.listen-retries", 5, "Number of times to retry binding web.listen-address, with exponential backoff starting at 100ms.")
+		serveRetries  = flag.Int("web.serve-retries", 0, "Number of times to restart the web server after a network error, with exponential backoff starting at 100ms.")
+		webConfigFile = flag.String("web.config.file", "", "Path to a web configuration file enabling TLS and/or basic authentication.")
+		scrapeTimeout = flag.Float64("web.collect-timeout-seconds", 5, "Overall timeout in seconds for collecting passenger's status during a scrape. 0 disables the timeout.")
 		listenAddress = flag.String("web.listen-address", ":9149", "Address to listen on for web interface and telemetry.")
+
+		disableGoCollector      = flag.Bool("web.disable-go-collector", false, "Do not export the exporter's own Go runtime metrics.")
+		disableProcessCollector = flag.Bool("web.disable-process-collector", false, "Do not export the exporter's own process metrics.")
 	)
+	flag.Var(&cmdArgs, "passenger.command.arg", "Argument to pass to passenger.command, which is then taken to be the binary alone. May be repeated.")
+	flag.Var(&cmdEnv, "passenger.command.env", "Environment variable in key=value form to set for passenger.command. May be repeated.")
 	flag.Parse()
 
+	if *selftestOnly {
+		if err := selftest(); err != nil {
+			log.Errorf("self-test failed: %s", err)
+			os.Exit(1)
+		}
+		log.Infoln("self-test passed")
+		os.Exit(0)
+	}
+
+	if err := flagsFromEnv(
+		"passenger.command",
+		"passenger.command.timeout-seconds",
+		"web.listen-address",
+	); err != nil {
+		log.Fatal(err)
+	}
+
+	if *configFile != "" {
+		if err := applyConfig(*configFile); err != nil {
+			log.Fatal(err)
+		}
+		reloadOnSIGHUP(*configFile)
+	}
+
+	if *disableGoCollector {
+		prometheus.Unregister(prometheus.NewGoCollector())
+	}
+	if *disableProcessCollector {
+		prometheus.Unregister(prometheus.NewProcessCollector(os.Getpid(), ""))
+	}
+
+	// The passenger_process_* metrics of the pidfile collector would clash with
+	// the process subsystem.
+	if *subsystems && *pidFile != "" {
+		log.Fatal("-passenger.metric-subsystems cannot be combined with -passenger.pid-file")
+	}
+
+	if *idStrategy != idStrategyBucket && *idStrategy != idStrategyGUPIDHash {
+		log.Fatalf("invalid -passenger.id-strategy %q, expected %s or %s", *idStrategy, idStrategyBucket, idStrategyGUPIDHash)
+	}
+
+	// Polling exporters would outlive the instances they were discovered for.
+	if *pollInterval > 0 && *discover {
+		log.Fatal("-passenger.poll-interval-seconds cannot be combined with -passenger.discover-instances")
+	}
+
+	// Discovered instances come and go, so there is no one set of ids to save.
+	if *idStateFile != "" && *discover {
+		log.Fatal("-passenger.id-state-file cannot be combined with -passenger.discover-instances")
+	}
+
+	// Discovered instances are queried with passenger.command.
+	if *statusURL != "" && *discover {
+		log.Fatal("-passenger.status-url cannot be combined with -passenger.discover-instances")
+	}
+
+	cmdBin := *cmd
+	if len(cmdArgs) == 0 {
+		cmdBin = strings.Split(*cmd, " ")[0]
+	}
+	if *statusURL == "" {
+		if err := checkCommand(cmdBin, *cmdDir); err != nil {
+			if !*allowMissing {
+				log.Fatal(err)
+			}
+			log.Warn(err)
+		}
+	}
+
+	// Only watch passenger's own process when a pidfile is configured.
 	if *pidFile != "" {
-		prometheus.MustRegister(prometheus.NewProcessCollectorPIDFn(
-			func() (int, error) {
-				content, err := ioutil.ReadFile(*pidFile)
-				if err != nil {
-					return 0, fmt.Errorf("error reading pidfile %q: %s", *pidFile, err)
-				}
-				value, err := strconv.Atoi(strings.TrimSpace(string(content)))
-				if err != nil {
-					return 0, fmt.Errorf("error parsing pidfile %q: %s", *pidFile, err)
-				}
-				return value, nil
-			},
-			namespace),
-		)
+		prometheus.MustRegister(newPIDFileCollector(*pidFile))
+	}
+
+	scrapes := newScrapeTracker()
+	opts := []ExporterOption{
+		WithScrapeTracker(scrapes),
+		WithCommandDir(*cmdDir),
+		WithCommandArgs(cmdArgs),
+		WithCommandEnv(cmdEnv),
+		WithCollectTimeout(time.Duration(*scrapeTimeout * nanosecondsPerSecond)),
+		WithSubsystems(*subsystems),
+		WithEnabledMetrics(splitList(*metricFilter)),
+		WithUpIgnoresParseErrors(*upIgnoreParse),
+		WithPersistOnFailure(*persistFailed),
+		WithIDStrategy(*idStrategy),
+		WithGUPIDLabel(*gupidLabel),
+		WithFirstSeenMetric(*firstSeen),
+		WithRequestsProcessedUntyped(*untypedReqs),
+		WithBaseURILabel(*baseURILabel),
+		WithEnvironmentLabel(*envLabel),
+		WithStartCommandLabel(*startCommand),
+		WithRestartTxt(*restartTxt),
+		WithSpawnDurationHistogram(*spawnHist),
+		WithProcessTitleInfo(*processTitle),
+		WithDetailedApps(splitList(*detailedApps)),
+		WithAppRequestQueueMaxReset(*queueMaxReset),
+		WithIdleProcessMinUptime(time.Duration(*idleUptime * nanosecondsPerSecond)),
+		WithTopMemoryProcesses(*topMemory),
+		WithMaxProcessAge(time.Duration(*maxProcessAge * nanosecondsPerSecond)),
+		WithMinScrapeInterval(time.Duration(*minScrape * nanosecondsPerSecond)),
+	}
+
+	if *helpOverrides != "" {
+		help, err := loadHelpOverrides(*helpOverrides)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, WithHelpOverrides(help))
+	}
+
+	if *hostLabel {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to determine hostname: %s", err)
+		}
+		opts = append(opts, WithConstLabels(prometheus.Labels{"host": hostname}))
 	}
 
-	prometheus.MustRegister(NewExporter(*cmd, *timeout))
+	exporter := NewExporter(*cmd, *timeout, opts...)
+	if *statusURL != "" {
+		if *urlTimeout <= 0 {
+			*urlTimeout = *timeout
+		}
+		exporter = NewExporterFromURL(*statusURL, *urlTimeout, opts...)
+	}
+	if *idStateFile != "" {
+		if err := exporter.loadProcessIdentifiers(*idStateFile); err != nil {
+			log.Fatal(err)
+		}
+		saveProcessIdentifiersOnShutdown(exporter, *idStateFile)
+	}
+	if *pollInterval > 0 {
+		exporter.startPolling(time.Duration(*pollInterval * nanosecondsPerSecond))
+	}
+
+	var collector prometheus.Collector = exporter
+	debugStatusHandler := exporter.debugStatusHandler()
+	if *discover {
+		discoverer := newInstanceDiscoverer(*registryDir, func(instance string) *Exporter {
+			instanceOpts := append([]ExporterOption{}, opts...)
+			instanceOpts = append(instanceOpts, WithConstLabels(prometheus.Labels{"passenger_instance": instance}))
+			if len(cmdArgs) > 0 {
+				instanceArgs := append([]string{}, cmdArgs...)
+				instanceOpts = append(instanceOpts, WithCommandArgs(append(instanceArgs, instance)))
+				return NewExporter(*cmd, *timeout, instanceOpts...)
+			}
+			return NewExporter(*cmd+" "+instance, *timeout, instanceOpts...)
+		})
+		collector = discoverer
+		debugStatusHandler = discoverer.debugStatusHandler()
+	}
+	if *primeOnStart {
+		prime(collector)
+	}
+	prometheus.MustRegister(collector)
+
+	if *pushURL != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to determine hostname: %s", err)
+		}
+		heartbeat := newHeartbeat()
+		prometheus.MustRegister(heartbeat)
+		pushHeartbeats(*pushURL, hostname, time.Duration(*pushInterval*nanosecondsPerSecond), heartbeat)
+	}
 
-	http.Handle(*metricsPath, prometheus.Handler())
+	http.Handle(*metricsPath, scrapes.handler(metricsHandler(*protobuf)))
+	if *debugStatus {
+		http.Handle("/debug/status", debugStatusHandler)
+	}
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Passenger Exporter</title></head>
@@ -458,6 +2984,12 @@ func main() {
 
 	log.Infoln("Starting passenger-exporter", version.Info())
 	log.Infoln("Build context", version.BuildContext())
-	log.Infoln("Listening on", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	log.Fatal(supervise(*serveRetries, func() error {
+		listener, err := listen(*listenAddress, *listenRetries)
+		if err != nil {
+			return err
+		}
+		log.Infoln("Listening on", *listenAddress)
+		return serve(listener, *webConfigFile, http.DefaultServeMux)
+	}))
 }
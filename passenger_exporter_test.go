@@ -2,17 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 var golden bool
@@ -26,7 +34,17 @@ func TestParsing(t *testing.T) {
 	tests := map[string]func(t *testing.T) *Info{
 		"newExporter": func(t *testing.T) *Info {
 			e := newTestExporter()
-			info, err := e.status()
+			info, err := e.status(context.Background())
+			if err != nil {
+				t.Fatalf("failed to get status: %v", err)
+			}
+			return info
+		},
+		"newExporterFromReader": func(t *testing.T) *Info {
+			e := NewExporterFromReader(func() (io.Reader, error) {
+				return os.Open("./test/passenger_xml_output.xml")
+			})
+			info, err := e.status(context.Background())
 			if err != nil {
 				t.Fatalf("failed to get status: %v", err)
 			}
@@ -38,79 +56,1999 @@ func TestParsing(t *testing.T) {
 				t.Fatalf("open xml file failed: %v", err)
 			}
 
-			info, err := parseOutput(f)
-			if err != nil {
-				t.Fatalf("parse xml file failed: %v", err)
-			}
-			return info
-		},
+			info, err := parseOutput(f)
+			if err != nil {
+				t.Fatalf("parse xml file failed: %v", err)
+			}
+			return info
+		},
+	}
+
+	for name, test := range tests {
+		info := test(t)
+
+		if len(info.SuperGroups) == 0 {
+			t.Fatalf("%v: no supergroups in output", name)
+		}
+		for _, sg := range info.SuperGroups {
+			if want, got := 1, len(sg.Groups); want != got {
+				t.Fatalf("%s: incorrect number of groups: wanted %d, got %d", name, want, got)
+			}
+			group := sg.Groups[0]
+			if want, got := "/src/app/my_app", group.Options.AppRoot; want != got {
+				t.Fatalf("%s: incorrect app_root: wanted %s, got %s", name, want, got)
+			}
+
+			if len(group.Processes) == 0 {
+				t.Fatalf("%v: no processes in output", name)
+			}
+			for _, proc := range group.Processes {
+				if want, got := "2254", proc.ProcessGroupID; want != got {
+					t.Fatalf("%s: incorrect process_group_id: wanted %s, got %s", name, want, got)
+				}
+			}
+		}
+	}
+}
+
+func TestScrape(t *testing.T) {
+	prometheus.MustRegister(newTestExporter())
+	server := httptest.NewServer(prometheus.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to GET test server: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+
+	scrapeFixturePath := "./test/scrape_output.txt"
+	if golden {
+		idx := bytes.Index(body, []byte("# HELP passenger_"))
+		ioutil.WriteFile(scrapeFixturePath, body[idx:], 0666)
+		t.Skipf("--golden passed: re-writing %s", scrapeFixturePath)
+	}
+
+	fixture, err := ioutil.ReadFile(scrapeFixturePath)
+	if err != nil {
+		t.Fatalf("failed to read scrape fixture: %v", err)
+	}
+
+	if !bytes.Contains(body, fixture) {
+		t.Fatalf("fixture data not contained within response body")
+	}
+}
+
+func TestAPIKeyNotExported(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newTestExporter())
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			for _, l := range m.Label {
+				if strings.Contains(l.GetValue(), "abc123cdf456") {
+					t.Fatalf("api key exported in label %s of %s", l.GetName(), mf.GetName())
+				}
+			}
+		}
+	}
+}
+
+// TestStatusURL collects from a mock server serving canned status, without
+// a passenger install.
+func TestStatusURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "./test/passenger_xml_output.xml")
+	}))
+	defer server.Close()
+
+	e := NewExporterFromURL(server.URL, time.Second.Seconds())
+	if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+	if want, got := "5.0.26", labelValue(gatherFamily(t, e, "passenger_version").Metric[0], "version"); want != got {
+		t.Fatalf("incorrect version: wanted %q, got %q", want, got)
+	}
+
+	server.Close()
+	if want, got := 0.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up once the server is gone: wanted %v, got %v", want, got)
+	}
+}
+
+func TestStatusURLTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	e := NewExporterFromURL(server.URL, 0.05)
+	if want, got := 0.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up for a hanging server: wanted %v, got %v", want, got)
+	}
+}
+
+func TestDebugStatusHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestExporter().debugStatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/status", nil))
+
+	if want, got := http.StatusOK, rec.Code; want != got {
+		t.Fatalf("incorrect status code: wanted %d, got %d", want, got)
+	}
+	var info Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if want, got := "5.0.26", info.PassengerVersion; want != got {
+		t.Fatalf("incorrect passenger version: wanted %q, got %q", want, got)
+	}
+	for name, secret := range map[string]string{
+		"api key":             "abc123cdf456",
+		"ust router username": `"logging"`,
+		"ust router password": "cdf456abc123",
+	} {
+		if strings.Contains(rec.Body.String(), secret) {
+			t.Fatalf("%s not redacted", name)
+		}
+	}
+}
+
+func TestDebugStatusMinScrapeInterval(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	var fetches int
+	now := time.Unix(1462500000, 0)
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		fetches++
+		return bytes.NewReader(fixture), nil
+	}, WithMinScrapeInterval(10*time.Second))
+	e.now = func() time.Time { return now }
+
+	// The status of a scrape is reused, as is that of a debug request.
+	gatherFamily(t, e, "passenger_up")
+	for _, tc := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{5 * time.Second, 1},
+		{10 * time.Second, 2},
+		{5 * time.Second, 2},
+	} {
+		now = now.Add(tc.after)
+		rec := httptest.NewRecorder()
+		e.debugStatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/status", nil))
+		if want, got := http.StatusOK, rec.Code; want != got {
+			t.Fatalf("incorrect status code: wanted %d, got %d", want, got)
+		}
+		if tc.want != fetches {
+			t.Fatalf("incorrect number of fetches: wanted %d, got %d", tc.want, fetches)
+		}
+	}
+}
+
+func TestInstanceInfo(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	withID := bytes.Replace(fixture, []byte("</passenger_version>"), []byte("</passenger_version><instance_id>ZR4JaCyS</instance_id>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(withID), nil
+	})
+	mf := gatherFamily(t, e, "passenger_instance_info")
+	if want, got := "ZR4JaCyS", labelValue(mf.Metric[0], "uuid"); want != got {
+		t.Fatalf("incorrect uuid: wanted %q, got %q", want, got)
+	}
+}
+
+func TestStatusOutputBytes(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	mf := gatherFamily(t, newTestExporter(), "passenger_status_output_bytes")
+	if want, got := float64(len(fixture)), mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect output size: wanted %v, got %v", want, got)
+	}
+}
+
+func TestGUPIDLabel(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithGUPIDLabel(true))
+
+	mf := gatherFamily(t, e, "passenger_requests_processed_total")
+	if len(mf.Metric) == 0 {
+		t.Fatalf("no passenger_requests_processed_total metrics")
+	}
+	for _, m := range mf.Metric {
+		if labelValue(m, "gupid") == "" {
+			t.Fatalf("missing gupid label: %v", m)
+		}
+	}
+}
+
+func TestRequestsProcessedUntyped(t *testing.T) {
+	for _, tc := range []struct {
+		untyped bool
+		want    dto.MetricType
+	}{
+		{untyped: false, want: dto.MetricType_COUNTER},
+		{untyped: true, want: dto.MetricType_UNTYPED},
+	} {
+		e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithRequestsProcessedUntyped(tc.untyped))
+
+		mf := gatherFamily(t, e, "passenger_requests_processed_total")
+		if got := mf.GetType(); tc.want != got {
+			t.Fatalf("incorrect type with untyped %t: wanted %s, got %s", tc.untyped, tc.want, got)
+		}
+	}
+}
+
+func TestEnabledMetrics(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(),
+		WithEnabledMetrics([]string{"passenger_up", "passenger_app_request_queue"}),
+	)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	var names []string
+	for _, mf := range families {
+		names = append(names, mf.GetName())
+	}
+	if want := []string{"passenger_app_request_queue", "passenger_up"}; !reflect.DeepEqual(want, names) {
+		t.Fatalf("incorrect metrics: wanted %v, got %v", want, names)
+	}
+
+	descs := make(chan *prometheus.Desc, 100)
+	e.Describe(descs)
+	close(descs)
+	if want, got := 2, len(descs); want != got {
+		t.Fatalf("incorrect number of described metrics: wanted %d, got %d", want, got)
+	}
+}
+
+func TestSubsystems(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithSubsystems(true))
+
+	for _, name := range []string{
+		"passenger_app_count",
+		"passenger_app_request_queue",
+		"passenger_process_memory",
+		"passenger_process_requests_processed_total",
+	} {
+		gatherFamily(t, e, name)
+	}
+}
+
+func TestBaseURILabel(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithBaseURILabel(true))
+
+	for _, name := range []string{"passenger_app_request_queue", "passenger_app_startup_info"} {
+		mf := gatherFamily(t, e, name)
+		if want, got := "/", labelValue(mf.Metric[0], "base_uri"); want != got {
+			t.Fatalf("%s: incorrect base_uri: wanted %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestConstLabels(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(),
+		WithConstLabels(prometheus.Labels{"host": "web-1"}),
+		WithConstLabels(prometheus.Labels{"instance": "main"}),
+	)
+
+	mf := gatherFamily(t, e, "passenger_up")
+	for name, want := range map[string]string{"host": "web-1", "instance": "main"} {
+		if got := labelValue(mf.Metric[0], name); want != got {
+			t.Fatalf("incorrect %s: wanted %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestHelpOverrides(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(),
+		WithHelpOverrides(map[string]string{"passenger_up": "Whether passenger is reachable."}),
+	)
+
+	if want, got := "Whether passenger is reachable.", gatherFamily(t, e, "passenger_up").GetHelp(); want != got {
+		t.Fatalf("incorrect help: wanted %q, got %q", want, got)
+	}
+	if want, got := "Number of apps.", gatherFamily(t, e, "passenger_app_count").GetHelp(); want != got {
+		t.Fatalf("incorrect help: wanted %q, got %q", want, got)
+	}
+}
+
+func TestEnvironmentLabel(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithEnvironmentLabel(true))
+
+	for _, name := range []string{
+		"passenger_app_request_queue",
+		"passenger_proc_memory",
+		"passenger_proc_restarts_total",
+		"passenger_tracked_processes",
+	} {
+		mf := gatherFamily(t, e, name)
+		if want, got := "production", labelValue(mf.Metric[0], "environment"); want != got {
+			t.Fatalf("incorrect environment on %s: wanted %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestProcessTitleInfo(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithProcessTitleInfo(true))
+
+	mf := gatherFamily(t, e, "passenger_app_process_title")
+	if want, got := "Passenger RubyApp", labelValue(mf.Metric[0], "process_title"); want != got {
+		t.Fatalf("incorrect process_title: wanted %q, got %q", want, got)
+	}
+}
+
+func TestRestartTxt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "restart_dir")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	withRestartDir := bytes.Replace(fixture, []byte("</app_root>"), []byte("</app_root><restart_dir>"+dir+"</restart_dir>"), -1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(withRestartDir), nil
+	}, WithRestartTxt(true))
+
+	mf := gatherFamily(t, e, "passenger_app_restart_info")
+	if want, got := dir, labelValue(mf.Metric[0], "restart_dir"); want != got {
+		t.Fatalf("incorrect restart_dir: wanted %q, got %q", want, got)
+	}
+	if want, got := 0.0, gatherFamily(t, e, "passenger_app_restart_txt_present").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect restart.txt presence: wanted %v, got %v", want, got)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "restart.txt"), nil, 0644); err != nil {
+		t.Fatalf("failed to write restart.txt: %v", err)
+	}
+	if want, got := 1.0, gatherFamily(t, e, "passenger_app_restart_txt_present").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect restart.txt presence: wanted %v, got %v", want, got)
+	}
+}
+
+func TestStickyCookieInfo(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	withAttrs := bytes.Replace(fixture, []byte("</api_key>"), []byte("</api_key><sticky_sessions_cookie_attributes>SameSite=Lax; Secure;</sticky_sessions_cookie_attributes>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(withAttrs), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_sticky_cookie_info")
+	if want, got := "SameSite=Lax; Secure;", labelValue(mf.Metric[0], "attributes"); want != got {
+		t.Fatalf("incorrect attributes: wanted %q, got %q", want, got)
+	}
+
+	// Nothing is exported for apps without attributes.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newTestExporter())
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "passenger_app_sticky_cookie_info" {
+			t.Fatalf("unexpected sticky cookie info without attributes")
+		}
+	}
+}
+
+func TestStartCommandLabel(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithStartCommandLabel(true))
+
+	mf := gatherFamily(t, e, "passenger_app_startup_info")
+	if want, got := "/usr/local/rvm/wrappers/my_app/ruby\t/usr/share/passenger/helper-scripts/rack-loader.rb", labelValue(mf.Metric[0], "start_command"); want != got {
+		t.Fatalf("incorrect start_command: wanted %q, got %q", want, got)
+	}
+}
+
+func TestSupergroupNotReady(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	restarting := bytes.Replace(fixture, []byte("<state>READY</state>"), []byte("<state>RESTARTING</state>"), 1)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(restarting), nil
+	}))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "passenger_supergroup_ready":
+			if want, got := 0.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+				t.Fatalf("incorrect supergroup_ready: wanted %v, got %v", want, got)
+			}
+		case "passenger_proc_memory":
+			t.Fatalf("unexpected process metrics for supergroup which is not ready")
+		}
+	}
+}
+
+func TestDisabledWithSessions(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The first process has an open session.
+	disabled := bytes.Replace(fixture, []byte("<enabled>ENABLED</enabled>"), []byte("<enabled>DISABLED</enabled>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(disabled), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_disabled_with_sessions")
+	if want, got := 1.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect disabled processes with sessions: wanted %v, got %v", want, got)
+	}
+}
+
+func TestProcessCPU(t *testing.T) {
+	e := newTestExporter()
+	cpu := gatherFamily(t, e, "passenger_proc_cpu")
+	memory := gatherFamily(t, e, "passenger_proc_memory")
+
+	if want, got := len(memory.Metric), len(cpu.Metric); want != got {
+		t.Fatalf("incorrect number of cpu series: wanted %d, got %d", want, got)
+	}
+	var total float64
+	for i, m := range cpu.Metric {
+		for _, label := range []string{"name", "id"} {
+			if want, got := labelValue(memory.Metric[i], label), labelValue(m, label); want != got {
+				t.Fatalf("incorrect %s on cpu series: wanted %q, got %q", label, want, got)
+			}
+		}
+		total += m.GetGauge().GetValue()
+	}
+	if want, got := gatherFamily(t, e, "passenger_app_cpu_total").Metric[0].GetGauge().GetValue(), total; want != got {
+		t.Fatalf("incorrect cpu across processes: wanted %v, got %v", want, got)
+	}
+}
+
+func TestProcessLoad(t *testing.T) {
+	e := newTestExporter()
+	// The fixture's ten processes with a session are each at maximum
+	// busyness.
+	for name, want := range map[string]float64{
+		"passenger_proc_sessions":    10,
+		"passenger_proc_concurrency": 48,
+		"passenger_proc_busyness":    10 * 2147483647,
+	} {
+		var got float64
+		for _, m := range gatherFamily(t, e, name).Metric {
+			got += m.GetGauge().GetValue()
+		}
+		if want != got {
+			t.Fatalf("incorrect %s across processes: wanted %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestBusyProcessFraction(t *testing.T) {
+	e := newTestExporter()
+	if want, got := 10.0/48, gatherFamily(t, e, "passenger_app_busy_process_fraction").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect busy process fraction: wanted %v, got %v", want, got)
+	}
+}
+
+func TestNoApps(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	idle := []byte(`<?xml version="1.0" encoding="iso8859-1" ?>
+<info version="3">
+  <passenger_version>5.0.26</passenger_version>
+  <group_count>0</group_count>
+  <process_count>0</process_count>
+  <max>48</max>
+  <capacity_used>0</capacity_used>
+  <get_wait_list_size>0</get_wait_list_size>
+  <supergroups>
+  </supergroups>
+</info>`)
+
+	// Apps are undeployed after the first scrape.
+	outputs := [][]byte{fixture, idle}
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		out := outputs[0]
+		if len(outputs) > 1 {
+			outputs = outputs[1:]
+		}
+		return bytes.NewReader(out), nil
+	})
+	gatherFamily(t, e, "passenger_app_group_count")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	// Instance-wide metrics sharing the app and process prefixes.
+	instanceWide := map[string]bool{
+		"passenger_app_count":       true,
+		"passenger_app_group_count": true,
+	}
+	values := make(map[string]float64)
+	for _, mf := range families {
+		if strings.HasPrefix(mf.GetName(), "passenger_app_") && !instanceWide[mf.GetName()] {
+			t.Fatalf("stale app metric %s exported without apps", mf.GetName())
+		}
+		if strings.HasPrefix(mf.GetName(), "passenger_proc_") && !instanceWide[mf.GetName()] {
+			t.Fatalf("stale process metric %s exported without apps", mf.GetName())
+		}
+		values[mf.GetName()] = mf.Metric[0].GetGauge().GetValue()
+	}
+	for name, want := range map[string]float64{
+		"passenger_up":                1,
+		"passenger_app_count":         0,
+		"passenger_app_group_count":   0,
+		"passenger_current_processes": 0,
+	} {
+		if got, ok := values[name]; !ok || want != got {
+			t.Fatalf("incorrect %s: wanted %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestTimestampAnomalies(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The first process was last used before it finished spawning, the
+	// second finished spawning after the test's current time.
+	skewed := bytes.Replace(fixture, []byte("<last_used>1462479725218338</last_used>"), []byte("<last_used>1462477631572023</last_used>"), 1)
+	skewed = bytes.Replace(skewed, []byte("<spawn_end_time>1462477642289408</spawn_end_time>"), []byte("<spawn_end_time>1562477642289408</spawn_end_time>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(skewed), nil
+	})
+	e.now = func() time.Time { return time.Unix(1462500000, 0) }
+	if want, got := 2.0, gatherFamily(t, e, "passenger_instance_timestamp_anomalies").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect timestamp anomalies: wanted %v, got %v", want, got)
+	}
+}
+
+func TestCapacityUsed(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// Capacity is reported for the instance, the supergroup and the group,
+	// in that order.
+	for _, used := range []string{"40", "30", "20"} {
+		fixture = bytes.Replace(fixture, []byte("<capacity_used>48</capacity_used>"), []byte("<capacity_used>"+used+"</capacity_used>"), 1)
+	}
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(fixture), nil
+	})
+	for name, want := range map[string]float64{
+		"passenger_capacity_used":            40,
+		"passenger_supergroup_capacity_used": 30,
+		"passenger_app_capacity_used":        20,
+	} {
+		if got := gatherFamily(t, e, name).Metric[0].GetGauge().GetValue(); want != got {
+			t.Fatalf("incorrect %s: wanted %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestProcessesByState(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// Two processes are mid-drain during a restart.
+	restarting := bytes.Replace(fixture, []byte("<enabled_process_count>48</enabled_process_count>"), []byte("<enabled_process_count>45</enabled_process_count>"), 1)
+	restarting = bytes.Replace(restarting, []byte("<disabling_process_count>0</disabling_process_count>"), []byte("<disabling_process_count>2</disabling_process_count>"), 1)
+	restarting = bytes.Replace(restarting, []byte("<disabled_process_count>0</disabled_process_count>"), []byte("<disabled_process_count>1</disabled_process_count>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(restarting), nil
+	})
+	want := map[string]float64{"enabled": 45, "disabling": 2, "disabled": 1}
+	got := make(map[string]float64)
+	for _, m := range gatherFamily(t, e, "passenger_app_processes").Metric {
+		got[labelValue(m, "state")] = m.GetGauge().GetValue()
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect processes by state: wanted %v, got %v", want, got)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	swapping := bytes.Replace(fixture, []byte("<swap>0</swap>"), []byte("<swap>2</swap>"), 2)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(swapping), nil
+	})
+	if want, got := 2.0, gatherFamily(t, e, "passenger_app_processes_swapping").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect swapping processes: wanted %v, got %v", want, got)
+	}
+	if want, got := 4096.0, gatherFamily(t, e, "passenger_app_swap_bytes").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect swap: wanted %v, got %v", want, got)
+	}
+}
+
+func TestSurgeProcesses(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	limited := bytes.Replace(fixture, []byte("<max_processes>0</max_processes>"), []byte("<max_processes>40</max_processes>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(limited), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_surge_processes")
+	if want, got := 8.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect surge processes: wanted %v, got %v", want, got)
+	}
+}
+
+func TestInconsistentStateProcesses(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The group's life status precedes those of the first two processes.
+	shuttingDown := bytes.Replace(fixture, []byte("<life_status>ALIVE</life_status>"), []byte("<life_status>SHUTDOWN_TRIGGERED</life_status>"), 3)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(shuttingDown), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_inconsistent_state_processes")
+	if want, got := 2.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect inconsistent state processes: wanted %v, got %v", want, got)
+	}
+}
+
+func TestSpawnerGenerations(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	respawned := bytes.Replace(fixture, []byte("<spawner_creation_time>1460126877627875</spawner_creation_time>"), []byte("<spawner_creation_time>1462477600000000</spawner_creation_time>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(respawned), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_spawner_generations")
+	if want, got := 2.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect spawner generations: wanted %v, got %v", want, got)
+	}
+}
+
+func TestProcessesByConcurrency(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	drifted := bytes.Replace(fixture, []byte("<concurrency>1</concurrency>"), []byte("<concurrency>5</concurrency>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(drifted), nil
+	})
+	counts := make(map[string]float64)
+	for _, m := range gatherFamily(t, e, "passenger_app_processes_by_concurrency").Metric {
+		counts[labelValue(m, "concurrency")] = m.GetGauge().GetValue()
+	}
+	if want := map[string]float64{"1": 47, "5": 1}; !reflect.DeepEqual(want, counts) {
+		t.Fatalf("incorrect processes by concurrency: wanted %v, got %v", want, counts)
+	}
+}
+
+func TestMultipleGroups(t *testing.T) {
+	status := `<?xml version="1.0" encoding="iso8859-1" ?>
+<info version="3">
+  <passenger_version>5.0.26</passenger_version>
+  <group_count>2</group_count>
+  <process_count>2</process_count>
+  <max>4</max>
+  <supergroups>
+    <supergroup>
+      <name>/srv/app/my_app (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/my_app (production)</name>
+        <component_name>/srv/app/my_app (production)</component_name>
+        <processes>
+          <process><pid>100</pid><real_memory>1000</real_memory></process>
+        </processes>
+      </group>
+      <group>
+        <name>/srv/app/my_app (production)</name>
+        <component_name>worker</component_name>
+        <processes>
+          <process><pid>200</pid><real_memory>2000</real_memory></process>
+        </processes>
+      </group>
+    </supergroup>
+  </supergroups>
+</info>`
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return strings.NewReader(status), nil
+	})
+	memory := make(map[string]float64)
+	for _, m := range gatherFamily(t, e, "passenger_proc_memory").Metric {
+		memory[labelValue(m, "name")] = m.GetGauge().GetValue()
+	}
+	want := map[string]float64{
+		"/srv/app/my_app (production)":        1000,
+		"/srv/app/my_app (production)#worker": 2000,
+	}
+	if !reflect.DeepEqual(want, memory) {
+		t.Fatalf("incorrect process memory by app: wanted %v, got %v", want, memory)
+	}
+}
+
+func TestDuplicateComponentNames(t *testing.T) {
+	status := `<?xml version="1.0" encoding="iso8859-1" ?>
+<info version="3">
+  <passenger_version>5.0.26</passenger_version>
+  <max>4</max>
+  <supergroups>
+    <supergroup>
+      <name>/srv/app/my_app (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/my_app (production)</name>
+        <uuid>a</uuid>
+        <processes>
+          <process><pid>100</pid><real_memory>1000</real_memory></process>
+        </processes>
+      </group>
+      <group>
+        <name>/srv/app/my_app (production)</name>
+        <component_name>worker</component_name>
+        <uuid>b</uuid>
+        <processes>
+          <process><pid>200</pid><real_memory>2000</real_memory></process>
+        </processes>
+      </group>
+      <group>
+        <name>/srv/app/my_app (production)</name>
+        <component_name>worker</component_name>
+        <uuid>c</uuid>
+        <processes>
+          <process><pid>300</pid><real_memory>3000</real_memory></process>
+        </processes>
+      </group>
+      <group>
+        <name>/srv/app/my_app (production)</name>
+        <uuid>d</uuid>
+        <processes>
+          <process><pid>400</pid><real_memory>4000</real_memory></process>
+        </processes>
+      </group>
+    </supergroup>
+  </supergroups>
+</info>`
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return strings.NewReader(status), nil
+	})
+	memory := make(map[string]float64)
+	for _, m := range gatherFamily(t, e, "passenger_proc_memory").Metric {
+		memory[labelValue(m, "name")] = m.GetGauge().GetValue()
+	}
+	want := map[string]float64{
+		"/srv/app/my_app (production)":          1000,
+		"/srv/app/my_app (production)#worker@b": 2000,
+		"/srv/app/my_app (production)#worker@c": 3000,
+		"/srv/app/my_app (production)#@d":       4000,
+	}
+	if !reflect.DeepEqual(want, memory) {
+		t.Fatalf("incorrect process memory by app: wanted %v, got %v", want, memory)
+	}
+
+	// Supergroup metrics are exported once for all of its groups.
+	byType := gatherFamily(t, e, "passenger_supergroup_processes_by_app_type")
+	if want, got := 1, len(byType.Metric); want != got {
+		t.Fatalf("incorrect number of processes_by_app_type series: wanted %d, got %d", want, got)
+	}
+	if want, got := 4.0, byType.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect processes of the supergroup: wanted %v, got %v", want, got)
+	}
+	for _, name := range []string{"passenger_supergroup_ready", "passenger_supergroup_capacity_used"} {
+		mf := gatherFamily(t, e, name)
+		if want, got := 1, len(mf.Metric); want != got {
+			t.Fatalf("incorrect number of %s series: wanted %d, got %d", name, want, got)
+		}
+		if want, got := "/srv/app/my_app (production)", labelValue(mf.Metric[0], "name"); want != got {
+			t.Fatalf("incorrect supergroup name on %s: wanted %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestAppsByLifeStatus(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The first life status is the group's, the others its processes'.
+	restarting := bytes.Replace(fixture, []byte("<life_status>ALIVE</life_status>"), []byte("<life_status>RESTARTING</life_status>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(restarting), nil
+	})
+	counts := make(map[string]float64)
+	for _, m := range gatherFamily(t, e, "passenger_apps_by_life_status").Metric {
+		counts[labelValue(m, "life_status")] = m.GetGauge().GetValue()
+	}
+	if want := map[string]float64{"RESTARTING": 1}; !reflect.DeepEqual(want, counts) {
+		t.Fatalf("incorrect apps by life status: wanted %v, got %v", want, counts)
+	}
+}
+
+func TestRollingRestartActive(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		status []byte
+		want   float64
+	}{
+		{name: "idle", status: fixture, want: 0},
+		{name: "restarting", status: bytes.Replace(fixture, []byte("<life_status>ALIVE</life_status>"), []byte("<life_status>RESTARTING</life_status>"), 1), want: 1},
+		{name: "disabling", status: bytes.Replace(fixture, []byte("<disabling_process_count>0</disabling_process_count>"), []byte("<disabling_process_count>2</disabling_process_count>"), 1), want: 1},
+	} {
+		status := tc.status
+		e := NewExporterFromReader(func() (io.Reader, error) {
+			return bytes.NewReader(status), nil
+		})
+		if got := gatherFamily(t, e, "passenger_rolling_restart_active").Metric[0].GetGauge().GetValue(); tc.want != got {
+			t.Fatalf("%s: incorrect rolling restart: wanted %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestDebuggerEnabled(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	debugging := bytes.Replace(fixture, []byte("<debugger>false</debugger>"), []byte("<debugger>true</debugger>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(debugging), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_debugger_enabled")
+	if want, got := 1.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect debugger state: wanted %v, got %v", want, got)
+	}
+}
+
+func TestDetailedApps(t *testing.T) {
+	for _, tc := range []struct {
+		apps []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"/srv/app/my_app (production)"}, true},
+		{[]string{"/srv/app/other_app (production)"}, false},
+	} {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithDetailedApps(tc.apps)))
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+
+		var found bool
+		for _, mf := range families {
+			if mf.GetName() == "passenger_proc_memory" {
+				found = true
+			}
+		}
+		if found != tc.want {
+			t.Fatalf("apps %v: incorrect process metrics presence: wanted %t, got %t", tc.apps, tc.want, found)
+		}
+	}
+}
+
+func TestGUPIDHashIDs(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithIDStrategy(idStrategyGUPIDHash), WithGUPIDLabel(true))
+
+	mf := gatherFamily(t, e, "passenger_proc_memory")
+	for _, m := range mf.Metric {
+		if want, got := fmt.Sprintf("%08x", gupidHash(labelValue(m, "gupid"))), labelValue(m, "id"); want != got {
+			t.Fatalf("incorrect id: wanted %q, got %q", want, got)
+		}
+	}
+	if len(e.processIdentifiers) != 0 {
+		t.Fatalf("process identifiers updated under the gupid-hash strategy")
+	}
+}
+
+func TestGUPIDHashBucketsPruned(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	}, WithIDStrategy(idStrategyGUPIDHash))
+
+	gatherFamily(t, e, "passenger_up")
+	// Every replacement process has a new gupid, and so a new bucket.
+	for i, pid := range []string{"99997", "99998", "99999"} {
+		status = bytes.Replace(fixture, []byte("<pid>1402</pid>"), []byte("<pid>"+pid+"</pid>"), 1)
+		status = bytes.Replace(status, []byte("173ed63-TeHDFL632j"), []byte(fmt.Sprintf("173ed63-replaced%d", i)), 1)
+		gatherFamily(t, e, "passenger_up")
+	}
+	if want, got := 48, len(e.bucketPIDs); want != got {
+		t.Fatalf("incorrect number of buckets kept: wanted %d, got %d", want, got)
+	}
+	if want, got := 48, len(e.bucketSampled); want != got {
+		t.Fatalf("incorrect number of samples kept: wanted %d, got %d", want, got)
+	}
+}
+
+func TestDetailedProcessMemory(t *testing.T) {
+	e := newTestExporter()
+
+	// Series of the same process share an index, sorted by their labels.
+	first := -1
+	for i, m := range gatherFamily(t, e, "passenger_proc_memory").Metric {
+		if m.GetGauge().GetValue() == 330012 {
+			first = i
+		}
+	}
+	if first < 0 {
+		t.Fatal("first process of the fixture not found")
+	}
+	for name, want := range map[string]float64{
+		"passenger_proc_memory_pss":           330147,
+		"passenger_proc_memory_swap":          0,
+		"passenger_proc_memory_private_dirty": 330012,
+		"passenger_proc_memory_vmsize":        530184,
+	} {
+		if got := gatherFamily(t, e, name).Metric[first].GetGauge().GetValue(); want != got {
+			t.Fatalf("incorrect %s: wanted %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestTopMemoryProcesses(t *testing.T) {
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds(), WithTopMemoryProcesses(2))
+
+	if want, got := 2, len(gatherFamily(t, e, "passenger_proc_memory").Metric); want != got {
+		t.Fatalf("incorrect number of process memory series: wanted %d, got %d", want, got)
+	}
+	if want, got := 48, len(gatherFamily(t, e, "passenger_requests_processed_total").Metric); want != got {
+		t.Fatalf("incorrect number of process request series: wanted %d, got %d", want, got)
+	}
+
+	processes := []Process{
+		{PID: "1", RealMemory: "100"},
+		{PID: "2", RealMemory: "300"},
+		{PID: "3", RealMemory: "200"},
+		{PID: "4", RealMemory: "50"},
+	}
+	top, other := largestMemory(processes, 2)
+	if want := map[string]bool{"2": true, "3": true}; !reflect.DeepEqual(want, top) {
+		t.Fatalf("incorrect largest processes: wanted %v, got %v", want, top)
+	}
+	if want := 150.0; want != other {
+		t.Fatalf("incorrect memory of other processes: wanted %v, got %v", want, other)
+	}
+}
+
+func TestProcessRestarts(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	f, err := ioutil.TempFile("", "passenger_xml_output")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	e := NewExporter("cat "+f.Name(), time.Second.Seconds())
+	restarts := func() float64 {
+		var total float64
+		for _, m := range gatherFamily(t, e, "passenger_proc_restarts_total").Metric {
+			total += m.GetCounter().GetValue()
+		}
+		return total
+	}
+
+	ioutil.WriteFile(f.Name(), fixture, 0666)
+	if want, got := 0.0, restarts(); want != got {
+		t.Fatalf("incorrect restarts on first scrape: wanted %v, got %v", want, got)
+	}
+
+	replaced := bytes.Replace(fixture, []byte("<pid>1402</pid>"), []byte("<pid>99999</pid>"), 1)
+	ioutil.WriteFile(f.Name(), replaced, 0666)
+	if want, got := 1.0, restarts(); want != got {
+		t.Fatalf("incorrect restarts after replacing a process: wanted %v, got %v", want, got)
+	}
+	if want, got := 1.0, restarts(); want != got {
+		t.Fatalf("incorrect restarts on unchanged scrape: wanted %v, got %v", want, got)
+	}
+}
+
+func TestProcessRestartsMultipleApps(t *testing.T) {
+	status := `<?xml version="1.0" encoding="iso8859-1" ?>
+<info version="3">
+  <passenger_version>5.0.26</passenger_version>
+  <group_count>2</group_count>
+  <process_count>4</process_count>
+  <max>4</max>
+  <supergroups>
+    <supergroup>
+      <name>/srv/app/first (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/first (production)</name>
+        <processes>
+          <process><pid>100</pid></process>
+          <process><pid>101</pid></process>
+        </processes>
+      </group>
+    </supergroup>
+    <supergroup>
+      <name>/srv/app/second (production)</name>
+      <state>READY</state>
+      <group default="true">
+        <name>/srv/app/second (production)</name>
+        <processes>
+          <process><pid>200</pid></process>
+          <process><pid>201</pid></process>
+        </processes>
+      </group>
+    </supergroup>
+  </supergroups>
+</info>`
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return strings.NewReader(status), nil
+	})
+	restarts := func() map[string]float64 {
+		total := make(map[string]float64)
+		for _, m := range gatherFamily(t, e, "passenger_proc_restarts_total").Metric {
+			total[labelValue(m, "name")] += m.GetCounter().GetValue()
+		}
+		return total
+	}
+
+	restarts()
+	// Each app keeps its ids across scrapes, rather than only the last one.
+	if want, got := map[string]float64{"/srv/app/first (production)": 0, "/srv/app/second (production)": 0}, restarts(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect restarts on unchanged scrape: wanted %v, got %v", want, got)
+	}
+
+	status = strings.Replace(status, "<pid>101</pid>", "<pid>102</pid>", 1)
+	if want, got := map[string]float64{"/srv/app/first (production)": 1, "/srv/app/second (production)": 0}, restarts(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("incorrect restarts after replacing a process: wanted %v, got %v", want, got)
+	}
+}
+
+func TestFirstSeenMetric(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	}, WithFirstSeenMetric(true))
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+
+	firstSeenAt := func() map[float64]int {
+		counts := make(map[float64]int)
+		for _, m := range gatherFamily(t, e, "passenger_proc_first_seen_timestamp_seconds").Metric {
+			counts[m.GetGauge().GetValue()]++
+		}
+		return counts
+	}
+
+	first := firstSeenAt()
+	if len(first) != 1 || first[100] == 0 {
+		t.Fatalf("incorrect first seen times on first scrape: %v", first)
+	}
+
+	now = time.Unix(200, 0)
+	status = bytes.Replace(fixture, []byte("<pid>1402</pid>"), []byte("<pid>99999</pid>"), 1)
+	if want, got := 1, firstSeenAt()[200]; want != got {
+		t.Fatalf("incorrect first seen times after replacing a process: wanted %d at 200, got %d", want, got)
+	}
+}
+
+func TestRequestRate(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	})
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+
+	rate := func(id string) float64 {
+		for _, m := range gatherFamily(t, e, "passenger_proc_requests_per_second").Metric {
+			if labelValue(m, "id") == id {
+				return m.GetGauge().GetValue()
+			}
+		}
+		t.Fatalf("no request rate for id %s", id)
+		return 0
+	}
+
+	if want, got := 0.0, rate("0"); want != got {
+		t.Fatalf("incorrect rate on first scrape: wanted %v, got %v", want, got)
+	}
+
+	// The first process served 20 more requests in 10 seconds.
+	now = now.Add(10 * time.Second)
+	status = bytes.Replace(fixture, []byte("<processed>43578</processed>"), []byte("<processed>43598</processed>"), 1)
+	if want, got := 2.0, rate("0"); want != got {
+		t.Fatalf("incorrect rate: wanted %v, got %v", want, got)
+	}
+
+	now = now.Add(10 * time.Second)
+	status = bytes.Replace(status, []byte("<pid>1402</pid>"), []byte("<pid>99999</pid>"), 1)
+	if want, got := 0.0, rate("0"); want != got {
+		t.Fatalf("incorrect rate after replacing the process: wanted %v, got %v", want, got)
+	}
+}
+
+func TestMemoryGrowth(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	})
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+
+	growth := func(id string) float64 {
+		for _, m := range gatherFamily(t, e, "passenger_proc_memory_growth_bytes_per_second").Metric {
+			if labelValue(m, "id") == id {
+				return m.GetGauge().GetValue()
+			}
+		}
+		t.Fatalf("no memory growth for id %s", id)
+		return 0
+	}
+
+	if want, got := 0.0, growth("0"); want != got {
+		t.Fatalf("incorrect growth on first scrape: wanted %v, got %v", want, got)
+	}
+
+	// The first process grew by 100KB in 10 seconds.
+	now = now.Add(10 * time.Second)
+	status = bytes.Replace(fixture, []byte("<real_memory>330012</real_memory>"), []byte("<real_memory>330112</real_memory>"), 1)
+	if want, got := 10240.0, growth("0"); want != got {
+		t.Fatalf("incorrect growth: wanted %v, got %v", want, got)
+	}
+
+	now = now.Add(10 * time.Second)
+	status = bytes.Replace(status, []byte("<pid>1402</pid>"), []byte("<pid>99999</pid>"), 1)
+	if want, got := 0.0, growth("0"); want != got {
+		t.Fatalf("incorrect growth after replacing the process: wanted %v, got %v", want, got)
+	}
+}
+
+func TestProcessCountChanged(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	start := bytes.Index(fixture, []byte("<process>"))
+	end := bytes.Index(fixture, []byte("</process>")) + len("</process>")
+	withoutFirst := append(append([]byte{}, fixture[:start]...), fixture[end:]...)
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	})
+	changed := func() float64 {
+		return gatherFamily(t, e, "passenger_app_process_count_changed").Metric[0].GetGauge().GetValue()
+	}
+
+	for i, tc := range []struct {
+		status []byte
+		want   float64
+	}{
+		{fixture, 0},
+		{fixture, 0},
+		{withoutFirst, 1},
+		{withoutFirst, 0},
+	} {
+		status = tc.status
+		if got := changed(); tc.want != got {
+			t.Fatalf("scrape %d: incorrect process count changed: wanted %v, got %v", i, tc.want, got)
+		}
+	}
+
+	// Counts of apps that are gone are dropped.
+	status = bytes.Replace(fixture, []byte("my_app &#40;production&#41;"), []byte("other_app &#40;production&#41;"), -1)
+	changed()
+	if _, ok := e.appProcessCounts["/srv/app/my_app (production)"]; ok {
+		t.Fatal("process count of app that is gone was kept")
+	}
+}
+
+func TestAppRequestQueueMax(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The third wait list is the group's.
+	queued := bytes.Replace(fixture, []byte("<get_wait_list_size>0<"), []byte("<get_wait_list_size>7<"), 3)
+
+	for _, tc := range []struct {
+		reset bool
+		want  float64
+	}{
+		{false, 7},
+		{true, 0},
+	} {
+		output := queued
+		e := NewExporterFromReader(func() (io.Reader, error) {
+			return bytes.NewReader(output), nil
+		}, WithAppRequestQueueMaxReset(tc.reset))
+
+		gatherFamily(t, e, "passenger_app_request_queue_max")
+		output = fixture
+		mf := gatherFamily(t, e, "passenger_app_request_queue_max")
+		if got := mf.Metric[0].GetGauge().GetValue(); tc.want != got {
+			t.Fatalf("reset %t: incorrect queue max: wanted %v, got %v", tc.reset, tc.want, got)
+		}
+	}
+}
+
+func TestAppRequestQueueMaxPolling(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	queued := bytes.Replace(fixture, []byte("<get_wait_list_size>0<"), []byte("<get_wait_list_size>7<"), 3)
+
+	for _, reset := range []bool{false, true} {
+		output := fixture
+		e := NewExporterFromReader(func() (io.Reader, error) {
+			return bytes.NewReader(output), nil
+		}, WithAppRequestQueueMaxReset(reset))
+		e.startPolling(time.Hour)
+		gatherFamily(t, e, "passenger_app_request_queue_max")
+
+		// The queue builds up and clears between scrapes.
+		output = queued
+		e.pollStatus()
+		output = fixture
+		e.pollStatus()
+		mf := gatherFamily(t, e, "passenger_app_request_queue_max")
+		if want, got := 7.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+			t.Fatalf("reset %t: incorrect queue max: wanted %v, got %v", reset, want, got)
+		}
+	}
+}
+
+func TestAppRequestQueueMaxPruned(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	queued := bytes.Replace(fixture, []byte("<get_wait_list_size>0<"), []byte("<get_wait_list_size>7<"), 3)
+
+	output := queued
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(output), nil
+	})
+	gatherFamily(t, e, "passenger_app_request_queue_max")
+
+	// The app is replaced by another between scrapes.
+	output = bytes.Replace(fixture, []byte("my_app &#40;production&#41;"), []byte("other_app &#40;production&#41;"), -1)
+	gatherFamily(t, e, "passenger_app_request_queue_max")
+	if _, ok := e.appQueueMax["/srv/app/my_app (production)"]; ok {
+		t.Fatal("queue max of app that is gone was kept")
+	}
+}
+
+func TestPrime(t *testing.T) {
+	e := newTestExporter()
+	prime(e)
+
+	if want, got := 48, len(e.bucketPIDs); want != got {
+		t.Fatalf("incorrect number of primed buckets: wanted %d, got %d", want, got)
+	}
+}
+
+func TestTruncatedStatusRetried(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		first  []byte
+		status bool
+		calls  int
+	}{
+		{name: "truncated", first: fixture[:len(fixture)/2], status: true, calls: 2},
+		{name: "empty", first: nil, status: true, calls: 2},
+		{name: "malformed", first: []byte("<info><max>48</info>"), status: false, calls: 1},
+	} {
+		var calls int
+		e := NewExporterFromReader(func() (io.Reader, error) {
+			calls++
+			if calls == 1 {
+				return bytes.NewReader(tc.first), nil
+			}
+			return bytes.NewReader(fixture), nil
+		})
+		e.truncatedRetryDelay = time.Millisecond
+
+		_, err := e.status(context.Background())
+		if tc.status != (err == nil) {
+			t.Fatalf("%s: unexpected err: %v", tc.name, err)
+		}
+		if calls != tc.calls {
+			t.Fatalf("%s: incorrect number of fetches: wanted %d, got %d", tc.name, tc.calls, calls)
+		}
+	}
+}
+
+func TestStatusTimeout(t *testing.T) {
+	e := NewExporter("sleep 1", time.Millisecond.Seconds())
+	_, err := e.status(context.Background())
+	if err == nil {
+		t.Fatalf("failed to timeout")
 	}
 
-	for name, test := range tests {
-		info := test(t)
+	if !strings.Contains(err.Error(), "status command timed out after 0.001000 seconds") {
+		t.Fatalf("incorrect err: %v", err)
+	}
+}
 
-		if len(info.SuperGroups) == 0 {
-			t.Fatalf("%v: no supergroups in output", name)
+func TestCommandWait(t *testing.T) {
+	e := NewExporter("sleep 1", 0.05)
+	wait := gatherFamily(t, e, "passenger_command_wait_seconds").Metric[0].GetGauge().GetValue()
+	if wait < 0.05 || wait >= 1 {
+		t.Fatalf("incorrect command wait: wanted the 0.05s timeout, got %v", wait)
+	}
+}
+
+func TestStatusStderrBytes(t *testing.T) {
+	e := NewExporter("sh", 1, WithCommandArgs([]string{"-c", "echo deprecated >&2; cat ./test/passenger_xml_output.xml"}))
+	if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+	if want, got := 11.0, gatherFamily(t, e, "passenger_status_stderr_bytes").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect stderr bytes: wanted %v, got %v", want, got)
+	}
+}
+
+func TestStatusCancelled(t *testing.T) {
+	e := NewExporter("sleep 5", 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := e.status(ctx)
+	if err == nil || !strings.Contains(err.Error(), "status command cancelled") {
+		t.Fatalf("incorrect err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("status command ran for %s after being cancelled", elapsed)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passenger status")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	path := filepath.Join(dir, "status output.xml")
+	if err := ioutil.WriteFile(path, fixture, 0644); err != nil {
+		t.Fatalf("failed to write xml fixture: %v", err)
+	}
+
+	e := NewExporter("cat", time.Second.Seconds(), WithCommandArgs([]string{path}))
+	if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	if err := checkCommand("cat", ""); err != nil {
+		t.Fatalf("unexpected error for existing command: %v", err)
+	}
+	if err := checkCommand("./passenger_exporter_test.go", "./test"); err == nil {
+		t.Fatal("expected error for command relative to the working directory")
+	}
+	if err := checkCommand("passenger-status-missing", ""); err == nil {
+		t.Fatal("expected error for missing command")
+	}
+}
+
+func TestStatusCommandEnvironment(t *testing.T) {
+	e := NewExporter("sh -c cat<$FIXTURE", time.Second.Seconds(),
+		WithCommandDir("./test"),
+		WithCommandEnv([]string{"FIXTURE=passenger_xml_output.xml"}),
+	)
+	info, err := e.status(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+
+	if want, got := "5.0.26", info.PassengerVersion; want != got {
+		t.Fatalf("incorrect passenger_version: wanted %s, got %s", want, got)
+	}
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	value := flag.String("test.from-env", "default", "")
+	os.Setenv("TEST_FROM_ENV", "from env")
+	defer os.Unsetenv("TEST_FROM_ENV")
+
+	if err := flagsFromEnv("test.from-env"); err != nil {
+		t.Fatalf("failed to set flag from env: %v", err)
+	}
+	if want, got := "from env", *value; want != got {
+		t.Fatalf("incorrect flag value: wanted %s, got %s", want, got)
+	}
+}
+
+func TestProcessHeadroom(t *testing.T) {
+	processes := make([]Process, 3)
+	for _, tc := range []struct {
+		name         string
+		max, current string
+		appMax       string
+		want         int
+	}{
+		{"unlimited app", "10", "3", "0", 7},
+		{"app limit", "10", "3", "4", 1},
+		{"pool limit", "10", "9", "6", 1},
+		{"over limit", "10", "3", "2", 0},
+	} {
+		info := &Info{MaxProcessCount: tc.max, CurrentProcessCount: tc.current}
+		group := Group{Options: Options{MaxProcesses: tc.appMax}, Processes: processes}
+		if got := processHeadroom(info, group); tc.want != got {
+			t.Fatalf("case %s: incorrect headroom: wanted %d, got %d", tc.name, tc.want, got)
 		}
-		for _, sg := range info.SuperGroups {
-			if want, got := "/src/app/my_app", sg.Group.Options.AppRoot; want != got {
-				t.Fatalf("%s: incorrect app_root: wanted %s, got %s", name, want, got)
-			}
+	}
+}
 
-			if len(sg.Group.Processes) == 0 {
-				t.Fatalf("%v: no processes in output", name)
-			}
-			for _, proc := range sg.Group.Processes {
-				if want, got := "2254", proc.ProcessGroupID; want != got {
-					t.Fatalf("%s: incorrect process_group_id: wanted %s, got %s", name, want, got)
-				}
+func TestMissingPIDFile(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newPIDFileCollector("./test/does_not_exist.pid"))
+	registry.MustRegister(newTestExporter())
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if strings.HasPrefix(mf.GetName(), "passenger_process_") {
+			t.Fatalf("unexpected process metric %s for missing pidfile", mf.GetName())
+		}
+	}
+	if len(families) == 0 {
+		t.Fatalf("no passenger metrics gathered")
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	for val, want := range map[string]time.Duration{
+		"0s":          0,
+		"34m 54s":     34*time.Minute + 54*time.Second,
+		"1d 2h 3m 4s": 26*time.Hour + 3*time.Minute + 4*time.Second,
+		"12h 0m 0s":   12 * time.Hour,
+		"":            0,
+	} {
+		got, err := parseUptime(val)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", val, err)
+		}
+		if want != got {
+			t.Fatalf("incorrect uptime for %q: wanted %s, got %s", val, want, got)
+		}
+	}
+
+	if _, err := parseUptime("3 weeks"); err == nil {
+		t.Fatalf("expected error for unknown unit")
+	}
+}
+
+func TestSpawnDurations(t *testing.T) {
+	processes := []Process{
+		{SpawnStartTime: "1000000", SpawnEndTime: "3000000"},
+		{SpawnStartTime: "1000000", SpawnEndTime: "1500000"},
+		{SpawnStartTime: "1000000", SpawnEndTime: "0"},
+	}
+	min, max, avg, ok := spawnDurations(processes)
+	if !ok {
+		t.Fatal("missing spawn durations")
+	}
+	if want := []float64{0.5, 2, 1.25}; !reflect.DeepEqual(want, []float64{min, max, avg}) {
+		t.Fatalf("incorrect min, max and avg: wanted %v, got %v", want, []float64{min, max, avg})
+	}
+
+	if _, _, _, ok := spawnDurations([]Process{{SpawnEndTime: "0"}}); ok {
+		t.Fatal("unexpected spawn durations for processes still spawning")
+	}
+}
+
+func TestSpawnEndRange(t *testing.T) {
+	first, last, ok := spawnEndRange([]Process{
+		{SpawnEndTime: "3000000"},
+		{SpawnEndTime: "4500000"},
+		{SpawnEndTime: "0"},
+	})
+	if want := time.Unix(3, 0); !ok || !want.Equal(first) {
+		t.Fatalf("incorrect first spawn time: wanted %v, got %v (%t)", want, first, ok)
+	}
+	if want := time.Unix(4, 500000000); !want.Equal(last) {
+		t.Fatalf("incorrect last spawn time: wanted %v, got %v", want, last)
+	}
+
+	if _, _, ok := spawnEndRange([]Process{{SpawnEndTime: "0"}}); ok {
+		t.Fatal("unexpected spawn times for processes still spawning")
+	}
+}
+
+func TestNewestProcess(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// The most recently spawned process is shutting down.
+	newest := []byte("<spawn_end_time>1462478111138392</spawn_end_time>")
+	i := bytes.Index(fixture, newest)
+	j := i + bytes.Index(fixture[i:], []byte("<life_status>ALIVE</life_status>"))
+	shutdown := append(append([]byte{}, fixture[:j]...), []byte("<life_status>SHUTTING_DOWN</life_status>")...)
+	shutdown = append(shutdown, fixture[j+len("<life_status>ALIVE</life_status>"):]...)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(shutdown), nil
+	})
+	e.now = func() time.Time { return time.Unix(1462500000, 0) }
+
+	for name, want := range map[string]float64{
+		"passenger_app_time_since_last_spawn_seconds": 1462500000 - 1462478111.138392,
+		"passenger_app_newest_process_seconds":        1462500000 - 1462478100.931419,
+	} {
+		if got := gatherFamily(t, e, name).Metric[0].GetGauge().GetValue(); math.Abs(want-got) > 1e-3 {
+			t.Fatalf("incorrect %s: wanted %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestSpawnDurationHistogram(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	}, WithSpawnDurationHistogram(true))
+
+	mf := gatherFamily(t, e, "passenger_app_spawn_duration_seconds")
+	if want, got := dto.MetricType_HISTOGRAM, mf.GetType(); want != got {
+		t.Fatalf("incorrect type: wanted %s, got %s", want, got)
+	}
+	sum := mf.Metric[0].GetHistogram().GetSampleSum()
+
+	// Processes already observed are not observed again, while their
+	// replacements are, so the histogram never goes down.
+	for _, want := range []uint64{48, 48} {
+		if got := gatherFamily(t, e, "passenger_app_spawn_duration_seconds").Metric[0].GetHistogram().GetSampleCount(); want != got {
+			t.Fatalf("incorrect count: wanted %d, got %d", want, got)
+		}
+	}
+	status = bytes.Replace(fixture, []byte("173ed63-TeHDFL632j"), []byte("173ed63-replaced"), 1)
+	h := gatherFamily(t, e, "passenger_app_spawn_duration_seconds").Metric[0].GetHistogram()
+	if want, got := uint64(49), h.GetSampleCount(); want != got {
+		t.Fatalf("incorrect count after replacement: wanted %d, got %d", want, got)
+	}
+	if h.GetSampleSum() <= sum {
+		t.Fatalf("sum did not grow after replacement: %v, then %v", sum, h.GetSampleSum())
+	}
+
+	// The histogram of an app that is gone is dropped.
+	status = bytes.Replace(fixture, []byte("my_app &#40;production&#41;"), []byte("other_app &#40;production&#41;"), -1)
+	mf = gatherFamily(t, e, "passenger_app_spawn_duration_seconds")
+	if want, got := 1, len(mf.Metric); want != got {
+		t.Fatalf("incorrect number of histograms: wanted %d, got %d", want, got)
+	}
+	if want, got := "/srv/app/other_app (production)", labelValue(mf.Metric[0], "name"); want != got {
+		t.Fatalf("incorrect app: wanted %s, got %s", want, got)
+	}
+}
+
+func TestProcessesOverMaxAge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e := newTestExporter()
+	e.now = func() time.Time { return now }
+	e.maxProcessAge = 100 * time.Second
+
+	processes := []Process{
+		{SpawnEndTime: "800000000"},
+		{SpawnEndTime: "950000000"},
+		{SpawnEndTime: "0"},
+	}
+	if want, got := 1, e.processesOverMaxAge(processes); want != got {
+		t.Fatalf("incorrect processes over max age: wanted %d, got %d", want, got)
+	}
+}
+
+func TestIdleProcesses(t *testing.T) {
+	processes := []Process{
+		{RequestsProcessed: "0", Uptime: "10m 0s"},
+		{RequestsProcessed: "0", Uptime: "1m 0s"},
+		{RequestsProcessed: "12", Uptime: "10m 0s"},
+	}
+	e := NewExporter("true", time.Second.Seconds(), WithIdleProcessMinUptime(5*time.Minute))
+	if want, got := 1, e.idleProcesses(processes); want != got {
+		t.Fatalf("incorrect idle processes: wanted %d, got %d", want, got)
+	}
+}
+
+func TestCollectTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		<-release
+		return nil, io.EOF
+	}, WithCollectTimeout(time.Millisecond))
+
+	if want, got := 0.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+	if want, got := 2.0, gatherFamily(t, e, "passenger_collect_timeouts_total").Metric[0].GetCounter().GetValue(); want != got {
+		t.Fatalf("incorrect collect timeouts: wanted %v, got %v", want, got)
+	}
+}
+
+func TestUpIgnoresParseErrors(t *testing.T) {
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return strings.NewReader("not xml"), nil
+	}, WithUpIgnoresParseErrors(true))
+
+	if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+	if want, got := 0.0, gatherFamily(t, e, "passenger_parse_success").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect parse success: wanted %v, got %v", want, got)
+	}
+
+	e = NewExporterFromReader(func() (io.Reader, error) {
+		return nil, io.EOF
+	}, WithUpIgnoresParseErrors(true))
+
+	if want, got := 0.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+}
+
+func TestPersistOnFailure(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	failing := false
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		if failing {
+			return nil, fmt.Errorf("passenger unavailable")
+		}
+		return bytes.NewReader(fixture), nil
+	}, WithPersistOnFailure(true))
+
+	gatherFamily(t, e, "passenger_up")
+	failing = true
+	if want, got := 0.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+	}
+	if want, got := 48, len(gatherFamily(t, e, "passenger_proc_memory").Metric); want != got {
+		t.Fatalf("incorrect number of persisted process series: wanted %d, got %d", want, got)
+	}
+}
+
+func TestPersistOnFailureRecovery(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	status, failing := fixture, false
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		if failing {
+			return nil, fmt.Errorf("passenger unavailable")
+		}
+		return bytes.NewReader(status), nil
+	}, WithPersistOnFailure(true))
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+
+	rate := func() float64 {
+		for _, m := range gatherFamily(t, e, "passenger_proc_requests_per_second").Metric {
+			if labelValue(m, "id") == "0" {
+				return m.GetGauge().GetValue()
 			}
 		}
+		t.Fatal("no request rate for id 0")
+		return 0
+	}
+
+	rate()
+	// The first process served 20 more requests in 10 seconds, the rate
+	// being replayed while passenger is unavailable.
+	now = now.Add(10 * time.Second)
+	status = bytes.Replace(fixture, []byte("<processed>43578</processed>"), []byte("<processed>43598</processed>"), 1)
+	if want, got := 2.0, rate(); want != got {
+		t.Fatalf("incorrect rate: wanted %v, got %v", want, got)
+	}
+	now = now.Add(10 * time.Second)
+	failing = true
+	if want, got := 2.0, rate(); want != got {
+		t.Fatalf("incorrect replayed rate: wanted %v, got %v", want, got)
+	}
+
+	// The replayed scrape must not count as a sample, so the rate after
+	// recovering spans the 20 seconds since the last fresh one.
+	now = now.Add(10 * time.Second)
+	failing = false
+	status = bytes.Replace(fixture, []byte("<processed>43578</processed>"), []byte("<processed>43658</processed>"), 1)
+	if want, got := 3.0, rate(); want != got {
+		t.Fatalf("incorrect rate after recovering: wanted %v, got %v", want, got)
 	}
 }
 
-func TestScrape(t *testing.T) {
-	prometheus.MustRegister(newTestExporter())
-	server := httptest.NewServer(prometheus.Handler())
-	defer server.Close()
+func TestPolling(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
 
-	res, err := http.Get(server.URL)
+	var polls int
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		polls++
+		return bytes.NewReader(fixture), nil
+	})
+	e.startPolling(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+			t.Fatalf("incorrect up: wanted %v, got %v", want, got)
+		}
+	}
+	if want, got := 1, polls; want != got {
+		t.Fatalf("scrapes did not use the polled status: wanted %d polls, got %d", want, got)
+	}
+}
+
+func TestPollingRequestRate(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
 	if err != nil {
-		t.Fatalf("failed to GET test server: %v", err)
+		t.Fatalf("failed to read xml fixture: %v", err)
 	}
-	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
+	status := fixture
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(status), nil
+	})
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+	e.startPolling(time.Hour)
+
+	rate := func() float64 {
+		for _, m := range gatherFamily(t, e, "passenger_proc_requests_per_second").Metric {
+			if labelValue(m, "id") == "0" {
+				return m.GetGauge().GetValue()
+			}
+		}
+		t.Fatal("no request rate for id 0")
+		return 0
+	}
+
+	rate()
+	now = now.Add(5 * time.Second)
+	rate()
+
+	// The first process served 20 more requests between polls 10 seconds
+	// apart, regardless of when the scrapes happen.
+	now = now.Add(5 * time.Second)
+	status = bytes.Replace(fixture, []byte("<processed>43578</processed>"), []byte("<processed>43598</processed>"), 1)
+	e.pollStatus()
+	for _, offset := range []time.Duration{2 * time.Second, 5 * time.Second} {
+		now = now.Add(offset)
+		if want, got := 2.0, rate(); want != got {
+			t.Fatalf("incorrect rate: wanted %v, got %v", want, got)
+		}
+	}
+}
+
+func TestMinScrapeInterval(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
 	if err != nil {
-		t.Fatalf("failed to read response body: %v", err)
+		t.Fatalf("failed to read xml fixture: %v", err)
 	}
 
-	scrapeFixturePath := "./test/scrape_output.txt"
-	if golden {
-		idx := bytes.Index(body, []byte("# HELP passenger_app_count Number of apps."))
-		ioutil.WriteFile(scrapeFixturePath, body[idx:], 0666)
-		t.Skipf("--golden passed: re-writing %s", scrapeFixturePath)
+	var queries int
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		queries++
+		return bytes.NewReader(fixture), nil
+	}, WithMinScrapeInterval(10*time.Second))
+	now := time.Unix(100, 0)
+	e.now = func() time.Time { return now }
+
+	for _, offset := range []time.Duration{0, 5 * time.Second, 15 * time.Second} {
+		now = time.Unix(100, 0).Add(offset)
+		if want, got := 1.0, gatherFamily(t, e, "passenger_up").Metric[0].GetGauge().GetValue(); want != got {
+			t.Fatalf("incorrect up after %s: wanted %v, got %v", offset, want, got)
+		}
+	}
+	if want, got := 2, queries; want != got {
+		t.Fatalf("incorrect number of passenger queries: wanted %d, got %d", want, got)
 	}
+}
 
-	fixture, err := ioutil.ReadFile(scrapeFixturePath)
+func TestInflightScrapes(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
 	if err != nil {
-		t.Fatalf("failed to read scrape fixture: %v", err)
+		t.Fatalf("failed to read xml fixture: %v", err)
 	}
 
-	if !bytes.Contains(body, fixture) {
-		t.Fatalf("fixture data not contained within response body")
+	release := make(chan struct{})
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		<-release
+		return bytes.NewReader(fixture), nil
+	})
+
+	inflight := func() float64 {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(ch)
+			close(ch)
+		}()
+		var value float64
+		for m := range ch {
+			if m.Desc() == e.inflightScrapesDesc {
+				var metric dto.Metric
+				m.Write(&metric)
+				value = metric.GetGauge().GetValue()
+			}
+		}
+		return value
+	}
+
+	// Hold the scrape which gets passenger's status first until the other
+	// one is waiting for it.
+	values := make(chan float64, 2)
+	for i := 0; i < 2; i++ {
+		go func() { values <- inflight() }()
+	}
+	for atomic.LoadInt32(&e.inflightScrapes) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if first, second := <-values, <-values; first != 2 && second != 2 {
+		t.Fatalf("incorrect inflight scrapes: wanted one scrape to see 2, got %v and %v", first, second)
 	}
 }
 
-func TestStatusTimeout(t *testing.T) {
-	e := NewExporter("sleep 1", time.Millisecond.Seconds())
-	_, err := e.status()
-	if err == nil {
-		t.Fatalf("failed to timeout")
+func TestStuckSpawning(t *testing.T) {
+	now := time.Unix(1000, 0)
+	e := newTestExporter()
+	e.now = func() time.Time { return now }
+
+	group := Group{
+		Options: Options{StartTimeout: "90000"},
+		Processes: []Process{
+			{SpawnStartTime: "800000000", SpawnEndTime: "0"},
+			{SpawnStartTime: "950000000", SpawnEndTime: "0"},
+			{SpawnStartTime: "800000000", SpawnEndTime: "810000000"},
+			{SpawnStartTime: "0", SpawnEndTime: "0"},
+		},
+	}
+	if want, got := 1, e.stuckSpawning(group); want != got {
+		t.Fatalf("incorrect stuck spawning processes: wanted %d, got %d", want, got)
 	}
+}
 
-	if !strings.Contains(err.Error(), "status command timed out after 0.001000 seconds") {
-		t.Fatalf("incorrect err: %v", err)
+func TestUnmappedProcesses(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+	// Processes which are yet to report their PID share a bucket.
+	pending := bytes.Replace(fixture, []byte("<pid>1402</pid>"), []byte("<pid></pid>"), 1)
+	pending = bytes.Replace(pending, []byte("<pid>1637</pid>"), []byte("<pid></pid>"), 1)
+
+	e := NewExporterFromReader(func() (io.Reader, error) {
+		return bytes.NewReader(pending), nil
+	})
+	mf := gatherFamily(t, e, "passenger_app_unmapped_processes")
+	if want, got := 1.0, mf.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect unmapped processes: wanted %v, got %v", want, got)
+	}
+}
+
+func TestMaxAppProcesses(t *testing.T) {
+	info := &Info{MaxProcessCount: "10"}
+	if want, got := 10, maxAppProcesses(info, Group{Options: Options{MaxProcesses: "0"}}); want != got {
+		t.Fatalf("incorrect max for unlimited app: wanted %d, got %d", want, got)
+	}
+	if want, got := 4, maxAppProcesses(info, Group{Options: Options{MaxProcesses: "4"}}); want != got {
+		t.Fatalf("incorrect max for limited app: wanted %d, got %d", want, got)
 	}
 }
 
@@ -224,6 +2162,19 @@ func TestUpdateProcessIdentifiers(t *testing.T) {
 			},
 			3,
 		),
+		newUpdateProcessSpec(
+			"zero max processes",
+			map[string]int{
+				"abc": 0,
+				"cdf": 1,
+			},
+			[]Process{
+				Process{PID: "abc"},
+				Process{PID: "cdf"},
+				Process{PID: "dfe"},
+			},
+			0,
+		),
 	} {
 		if len(spec.output) != len(spec.processes) {
 			t.Fatalf("case %s: proceses improperly copied to output: len(output) (%d) does not match len(processes) (%d)", spec.name, len(spec.output), len(spec.processes))
@@ -242,6 +2193,24 @@ func TestUpdateProcessIdentifiers(t *testing.T) {
 	}
 }
 
+func TestZeroMaxProcesses(t *testing.T) {
+	processes := []Process{
+		Process{PID: "abc"},
+		Process{PID: "cdf"},
+		Process{PID: "dfe"},
+	}
+	output := updateProcesses(map[string]int{}, processes, 0)
+
+	want := map[string]int{
+		"abc": 0,
+		"cdf": 1,
+		"dfe": 2,
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Fatalf("updateProcesses did not assign every process an id: wanted %v, got %v", want, output)
+	}
+}
+
 func TestInsertingNewProcesses(t *testing.T) {
 	spec := newUpdateProcessSpec(
 		"inserting processes",
@@ -272,6 +2241,37 @@ func TestInsertingNewProcesses(t *testing.T) {
 	}
 }
 
+// gatherFamily collects from e using a dedicated registry and returns the
+// metric family with the given name.
+func gatherFamily(t *testing.T, e *Exporter, name string) *dto.MetricFamily {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == name {
+			return mf
+		}
+	}
+	t.Fatalf("metric family %s not found", name)
+	return nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, lp := range m.Label {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
+
 func newTestExporter() *Exporter {
-	return NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds())
+	e := NewExporter("cat ./test/passenger_xml_output.xml", time.Second.Seconds())
+	// Keep metrics relative to the fixture's spawn times stable.
+	e.now = func() time.Time { return time.Unix(1462500000, 0) }
+	return e
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/log"
+)
+
+// pushJob is the job the heartbeat is pushed to the Pushgateway under.
+const pushJob = "passenger_exporter"
+
+// newHeartbeat returns the gauge pushed as the exporter's heartbeat.
+func newHeartbeat() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "exporter",
+		Name:      "heartbeat_timestamp_seconds",
+		Help:      "Unix time of the exporter's last heartbeat pushed to the Pushgateway.",
+	})
+}
+
+// pushHeartbeats sets heartbeat to the current time and pushes it to the
+// Pushgateway at gatewayURL every interval, grouped by instance so that
+// exporters on different hosts don't replace each other's heartbeat. Pushes
+// are independent of scrapes, so they keep going while /metrics hangs, and
+// time out after interval, so that a hanging Pushgateway doesn't stop them.
+func pushHeartbeats(gatewayURL, instance string, interval time.Duration, heartbeat prometheus.Gauge) {
+	client := &http.Client{Timeout: interval}
+	go func() {
+		for {
+			heartbeat.Set(float64(time.Now().UnixNano()) / nanosecondsPerSecond)
+			if err := push(client, gatewayURL, instance, heartbeat); err != nil {
+				log.Errorf("failed to push heartbeat: %s", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// push replaces the metrics of pushJob and instance on the Pushgateway at
+// gatewayURL with those of c, using client.
+func push(client *http.Client, gatewayURL, instance string, c prometheus.Collector) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(c); err != nil {
+		return err
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtProtoDelim)
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+
+	target := fmt.Sprintf("%s/metrics/job/%s/instance/%s",
+		strings.TrimSuffix(gatewayURL, "/"), url.PathEscape(pushJob), url.PathEscape(instance))
+	req, err := http.NewRequest(http.MethodPut, target, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtProtoDelim))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status from %s: %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestPush(t *testing.T) {
+	var (
+		method, path string
+		family       dto.MetricFamily
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.EscapedPath()
+		if err := expfmt.NewDecoder(r.Body, expfmt.FmtProtoDelim).Decode(&family); err != nil {
+			t.Errorf("failed to decode pushed metrics: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	heartbeat := newHeartbeat()
+	heartbeat.Set(1462500000)
+	if err := push(http.DefaultClient, server.URL+"/", "web 1", heartbeat); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+
+	if want, got := http.MethodPut, method; want != got {
+		t.Fatalf("incorrect method: wanted %s, got %s", want, got)
+	}
+	if want, got := "/metrics/job/passenger_exporter/instance/web%201", path; want != got {
+		t.Fatalf("incorrect path: wanted %s, got %s", want, got)
+	}
+	if want, got := "passenger_exporter_heartbeat_timestamp_seconds", family.GetName(); want != got {
+		t.Fatalf("incorrect metric: wanted %s, got %s", want, got)
+	}
+	if want, got := 1462500000.0, family.Metric[0].GetGauge().GetValue(); want != got {
+		t.Fatalf("incorrect heartbeat: wanted %v, got %v", want, got)
+	}
+}
+
+func TestPushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad push", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	if err := push(http.DefaultClient, server.URL, "web1", newHeartbeat()); err == nil {
+		t.Fatal("expected an error for a rejected push")
+	}
+}
+
+func TestPushTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	if err := push(client, server.URL, "web1", newHeartbeat()); err == nil {
+		t.Fatal("expected an error for a hanging Pushgateway")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// scrapeTracker tracks the requests being served by the metrics handler, so
+// that passenger's status command can be cancelled once every client waiting
+// for it has gone away, e.g. after Prometheus timed out the scrape.
+type scrapeTracker struct {
+	mutex sync.Mutex
+	live  int
+	// gone is closed when the number of live scrapes drops to zero.
+	gone chan struct{}
+}
+
+func newScrapeTracker() *scrapeTracker {
+	return &scrapeTracker{}
+}
+
+// track counts the scrape of ctx as live until ctx is done, which for an
+// HTTP request is when the client disconnects or the response is written.
+func (t *scrapeTracker) track(ctx context.Context) {
+	t.mutex.Lock()
+	if t.live == 0 {
+		t.gone = make(chan struct{})
+	}
+	t.live++
+	t.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.live--
+		if t.live == 0 {
+			close(t.gone)
+		}
+	}()
+}
+
+// context returns a context for collecting on behalf of the live scrapes,
+// cancelled once none of them remain. Collections without a live scrape,
+// such as priming, are never cancelled. The cancel function must be called
+// once collecting is done.
+func (t *scrapeTracker) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	t.mutex.Lock()
+	live, gone := t.live, t.gone
+	t.mutex.Unlock()
+	if live == 0 {
+		return ctx, cancel
+	}
+
+	go func() {
+		select {
+		case <-gone:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// handler wraps handler to track the scrapes it serves.
+func (t *scrapeTracker) handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.track(r.Context())
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScrapeTracker(t *testing.T) {
+	tracker := newScrapeTracker()
+
+	idle, cancelIdle := tracker.context()
+	defer cancelIdle()
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	tracker.track(first)
+	tracker.track(second)
+	ctx, cancel := tracker.context()
+	defer cancel()
+
+	cancelFirst()
+	select {
+	case <-ctx.Done():
+		t.Fatal("collection cancelled while a scrape is still live")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancelSecond()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("collection not cancelled once every scrape was gone")
+	}
+
+	if idle.Err() != nil {
+		t.Fatal("collection without a live scrape was cancelled")
+	}
+}
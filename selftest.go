@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selftest parses the copy of passenger's status bundled into the binary and
+// checks that its key fields came through, so that a build's parser can be
+// verified without passenger or the repository's test files.
+func selftest() error {
+	info, err := parseOutput(strings.NewReader(selftestFixture))
+	if err != nil {
+		return fmt.Errorf("failed to parse bundled status: %s", err)
+	}
+
+	if want, got := "5.0.26", info.PassengerVersion; want != got {
+		return fmt.Errorf("incorrect passenger version: wanted %q, got %q", want, got)
+	}
+	if want, got := 1, len(info.SuperGroups); want != got {
+		return fmt.Errorf("incorrect number of apps: wanted %d, got %d", want, got)
+	}
+	sg := info.SuperGroups[0]
+	if want, got := "/srv/app/my_app (production)", sg.Name; want != got {
+		return fmt.Errorf("incorrect app name: wanted %q, got %q", want, got)
+	}
+	if want, got := 1, len(sg.Groups); want != got {
+		return fmt.Errorf("incorrect number of groups: wanted %d, got %d", want, got)
+	}
+	group := sg.Groups[0]
+	if want, got := 48, len(group.Processes); want != got {
+		return fmt.Errorf("incorrect number of processes: wanted %d, got %d", want, got)
+	}
+	for _, proc := range group.Processes {
+		if proc.PID == "" || proc.RealMemory == "" || proc.RequestsProcessed == "" {
+			return fmt.Errorf("incomplete process %q", proc.GUPID)
+		}
+	}
+	return nil
+}
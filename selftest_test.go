@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSelftest(t *testing.T) {
+	if err := selftest(); err != nil {
+		t.Fatalf("self-test failed: %v", err)
+	}
+}
+
+// TestSelftestFixture keeps the status bundled for -selftest in sync with
+// the XML fixture, rewriting it when -golden is passed.
+func TestSelftestFixture(t *testing.T) {
+	fixture, err := ioutil.ReadFile("./test/passenger_xml_output.xml")
+	if err != nil {
+		t.Fatalf("failed to read xml fixture: %v", err)
+	}
+
+	var want bytes.Buffer
+	want.WriteString("// Code generated by go test -golden from test/passenger_xml_output.xml. DO NOT EDIT.\n\n")
+	want.WriteString("package main\n\n")
+	want.WriteString("// selftestFixture is the status parsed by -selftest.\n")
+	want.WriteString("const selftestFixture = `")
+	want.Write(fixture)
+	want.WriteString("`\n")
+
+	bundledPath := "./selftest_fixture.go"
+	if golden {
+		ioutil.WriteFile(bundledPath, want.Bytes(), 0666)
+		t.Skipf("--golden passed: re-writing %s", bundledPath)
+	}
+
+	bundled, err := ioutil.ReadFile(bundledPath)
+	if err != nil {
+		t.Fatalf("failed to read bundled fixture: %v", err)
+	}
+	if !bytes.Equal(want.Bytes(), bundled) {
+		t.Fatalf("%s is out of date, regenerate it with go test -golden", bundledPath)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// webConfig is the subset of the Prometheus exporter-toolkit web
+// configuration file supported by the exporter. Other settings are ignored,
+// so that existing web-config files can be reused.
+type webConfig struct {
+	TLSConfig tlsServerConfig   `yaml:"tls_server_config"`
+	Users     map[string]string `yaml:"basic_auth_users"`
+}
+
+// tlsServerConfig represents the tls_server_config section of the web
+// configuration file.
+type tlsServerConfig struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ClientAuth string `yaml:"client_auth_type"`
+	ClientCAs  string `yaml:"client_ca_file"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+func loadWebConfig(path string) (*webConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading web config %q: %s", path, err)
+	}
+
+	var c webConfig
+	if err := yaml.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("error parsing web config %q: %s", path, err)
+	}
+	// Settings the exporter doesn't support, such as http_server_config, are
+	// ignored so that files written for other exporters still load.
+	if err := yaml.UnmarshalStrict(content, &webConfig{}); err != nil {
+		log.Warnf("ignoring unsupported settings in web config %q: %s", path, err)
+	}
+	return &c, nil
+}
+
+// tlsConfig returns the TLS configuration to serve with, or nil when no
+// certificate is configured.
+func (c *webConfig) tlsConfig() (*tls.Config, error) {
+	tc := c.TLSConfig
+	if tc.CertFile == "" && tc.KeyFile == "" {
+		if tc.ClientAuth != "" || tc.ClientCAs != "" {
+			return nil, fmt.Errorf("client authentication requires cert_file and key_file")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %s", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	clientAuth, ok := clientAuthTypes[tc.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("invalid client_auth_type %q", tc.ClientAuth)
+	}
+	config.ClientAuth = clientAuth
+
+	if tc.ClientCAs != "" {
+		pem, err := ioutil.ReadFile(tc.ClientCAs)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client_ca_file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client_ca_file %q", tc.ClientCAs)
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// dummyHash is a bcrypt hash at the default cost of a password nobody is
+// meant to know.
+const dummyHash = "$2a$10$zguaEfgCsnmso7ZdGFFkkue.6kqtNUnYckkqx8Jp306F8p6j.AApK"
+
+// basicAuth wraps handler to require one of the given users, whose passwords
+// are bcrypt hashes.
+func basicAuth(handler http.Handler, users map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if ok {
+			// Compare unknown users' passwords against a dummy hash, so that
+			// response times don't reveal which users exist.
+			hash, found := users[user]
+			if !found {
+				hash = dummyHash
+			}
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil && found {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// listen binds addr, retrying with exponential backoff up to retries times
+// so that a quick restart can wait for the previous socket to be released.
+func listen(addr string, retries int) (net.Listener, error) {
+	backoff := 100 * time.Millisecond
+	for i := 0; ; i++ {
+		l, err := net.Listen("tcp", addr)
+		if err == nil || i >= retries || !retryable(err) {
+			return l, err
+		}
+
+		log.Warnf("failed to listen on %s, retrying in %s: %s", addr, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// supervise runs the web server with run, restarting it with exponential
+// backoff up to retries times when it fails with a retryable error.
+func supervise(retries int, run func() error) error {
+	backoff := 100 * time.Millisecond
+	for i := 0; ; i++ {
+		err := run()
+		if i >= retries || !retryable(err) {
+			return err
+		}
+
+		log.Warnf("web server failed, restarting in %s: %s", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retryable reports whether err is a network error which may go away, such
+// as the address being in use, as opposed to e.g. lacking permission to bind
+// it or a broken web config.
+func retryable(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	return !os.IsPermission(opErr.Err)
+}
+
+// serve serves handler on l, applying the TLS and basic auth settings of the
+// web config file at configPath, if any.
+func serve(l net.Listener, configPath string, handler http.Handler) error {
+	if configPath == "" {
+		return http.Serve(l, handler)
+	}
+
+	c, err := loadWebConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if len(c.Users) > 0 {
+		handler = basicAuth(handler, c.Users)
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig == nil {
+		return server.Serve(l)
+	}
+	return server.ServeTLS(l, "", "")
+}
+
+// protobufAccept is the Accept header of a client asking for the delimited
+// protobuf exposition format.
+const protobufAccept = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited`
+
+// metricsHandler returns the handler for the metrics endpoint. When
+// preferProtobuf is set, clients accepting the protobuf format, or not
+// stating a preference, are served protobuf even if they rank another format
+// higher.
+func metricsHandler(preferProtobuf bool) http.Handler {
+	handler := prometheus.Handler()
+	if !preferProtobuf {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if accept == "" || strings.Contains(accept, "application/vnd.google.protobuf") {
+			r.Header.Set("Accept", protobufAccept)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeWebConfig(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "web_config")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("failed to write web config: %v", err)
+	}
+	return f.Name()
+}
+
+func TestLoadWebConfig(t *testing.T) {
+	path := writeWebConfig(t, "basic_auth_users:\n  prometheus: hash\n")
+	defer os.Remove(path)
+
+	c, err := loadWebConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load web config: %v", err)
+	}
+	if want, got := "hash", c.Users["prometheus"]; want != got {
+		t.Fatalf("incorrect user hash: wanted %s, got %s", want, got)
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		t.Fatalf("failed to build tls config: %v", err)
+	}
+	if tlsConfig != nil {
+		t.Fatalf("unexpected tls config without certificate")
+	}
+}
+
+func TestLoadWebConfigUnsupportedSettings(t *testing.T) {
+	path := writeWebConfig(t, "tls_server_config:\n  min_version: TLS13\nhttp_server_config:\n  http2: false\nbasic_auth_users:\n  prometheus: hash\n")
+	defer os.Remove(path)
+
+	c, err := loadWebConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load web config with unsupported settings: %v", err)
+	}
+	if want, got := "hash", c.Users["prometheus"]; want != got {
+		t.Fatalf("incorrect user hash: wanted %s, got %s", want, got)
+	}
+
+	path = writeWebConfig(t, "basic_auth_users: [prometheus]\n")
+	defer os.Remove(path)
+	if _, err := loadWebConfig(path); err == nil {
+		t.Fatalf("expected error for malformed setting")
+	}
+}
+
+func TestListenRetries(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := taken.Addr().String()
+	time.AfterFunc(150*time.Millisecond, func() { taken.Close() })
+
+	if _, err := listen(addr, 0); err == nil {
+		t.Fatalf("expected error listening on taken address without retries")
+	}
+
+	l, err := listen(addr, 3)
+	if err != nil {
+		t.Fatalf("failed to listen after retries: %v", err)
+	}
+	l.Close()
+}
+
+func TestSupervise(t *testing.T) {
+	inUse := &net.OpError{Op: "listen", Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}
+	denied := &net.OpError{Op: "listen", Err: os.NewSyscallError("bind", syscall.EACCES)}
+
+	for _, tc := range []struct {
+		name    string
+		err     error
+		retries int
+		want    int
+	}{
+		{"address in use", inUse, 2, 3},
+		{"permission denied", denied, 2, 1},
+		{"web config", errors.New("error parsing web config"), 2, 1},
+		{"no retries", inUse, 0, 1},
+	} {
+		var runs int
+		err := supervise(tc.retries, func() error {
+			runs++
+			return tc.err
+		})
+		if err != tc.err {
+			t.Errorf("%s: wanted error %v, got %v", tc.name, tc.err, err)
+		}
+		if runs != tc.want {
+			t.Errorf("%s: wanted %d runs, got %d", tc.name, tc.want, runs)
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	handler := basicAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), map[string]string{"prometheus": string(hash)})
+
+	for _, tc := range []struct {
+		user, pass string
+		want       int
+	}{
+		{"prometheus", "secret", http.StatusOK},
+		{"prometheus", "wrong", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.SetBasicAuth(tc.user, tc.pass)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tc.want {
+			t.Fatalf("%s:%s: incorrect status: wanted %d, got %d", tc.user, tc.pass, tc.want, rec.Code)
+		}
+	}
+
+	if cost, err := bcrypt.Cost([]byte(dummyHash)); err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("dummy hash is not a bcrypt hash at the default cost: %d, %v", cost, err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Header().Get("WWW-Authenticate"), "Basic") {
+		t.Fatalf("missing WWW-Authenticate header")
+	}
+}
+
+func TestMetricsHandlerProtobuf(t *testing.T) {
+	for _, tc := range []struct {
+		preferProtobuf bool
+		accept         string
+		want           expfmt.Format
+	}{
+		{false, "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited", expfmt.FmtProtoDelim},
+		{false, "", expfmt.FmtText},
+		{true, "", expfmt.FmtProtoDelim},
+		{true, "application/vnd.google.protobuf;q=0.5,text/plain;q=0.9", expfmt.FmtProtoDelim},
+		{true, "text/plain", expfmt.FmtText},
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		rec := httptest.NewRecorder()
+		metricsHandler(tc.preferProtobuf).ServeHTTP(rec, req)
+
+		got := expfmt.Format(rec.Header().Get("Content-Type"))
+		if got != tc.want {
+			t.Errorf("prefer %v, accept %q: wanted content type %q, got %q", tc.preferProtobuf, tc.accept, tc.want, got)
+			continue
+		}
+
+		var mf dto.MetricFamily
+		if err := expfmt.NewDecoder(rec.Body, got).Decode(&mf); err != nil {
+			t.Errorf("prefer %v, accept %q: failed to decode response: %v", tc.preferProtobuf, tc.accept, err)
+		}
+	}
+}